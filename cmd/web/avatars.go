@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"snippetbox.ab.net/internal/avatar"
+	"snippetbox.ab.net/internal/filestore"
+	"snippetbox.ab.net/internal/models"
+	"strconv"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// maxAvatarUpload is the largest avatar file we accept.
+// 允许上传的头像文件的最大大小
+const maxAvatarUpload = 5 << 20
+
+// avatarKey is where one size of a user's avatar is kept in the file store.
+// 用户头像某个尺寸在文件存储中的位置
+func avatarKey(userID int, version int64, size int) string {
+	return fmt.Sprintf("avatars/%d/%d-%d.png", userID, version, size)
+}
+
+// avatarURL returns the URL of a user's avatar. The URL includes the avatar
+// version, so it changes whenever the picture does and can be cached forever.
+// 返回用户头像的 URL，URL 中包含头像版本号，图片改变时 URL 也会改变，所以可以永久缓存
+func avatarURL(user *models.User, size int) string {
+	return fmt.Sprintf("/avatar/%d/%d/%d", user.ID, user.AvatarVersion, size)
+}
+
+// deleteAvatarFiles removes every size of one version of a user's avatar.
+// 删除用户某个版本头像的所有尺寸
+func (app *application) deleteAvatarFiles(userID int, version int64) error {
+	if version == 0 {
+		return nil
+	}
+
+	for _, size := range avatar.Sizes {
+		err := app.files.Delete(avatarKey(userID, version, size))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// avatarImage serves an avatar, or an identicon for version 0. Responses are
+// the same for as long as the URL exists, so they're marked immutable.
+// 提供头像图片，版本 0 提供自动生成的 identicon。同一个 URL 的响应永远不变，所以标记为 immutable
+func (app *application) avatarImage(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+	version, err := strconv.ParseInt(params.ByName("version"), 10, 64)
+	if err != nil || version < 0 {
+		app.notFound(w)
+		return
+	}
+	size, err := strconv.Atoi(params.ByName("size"))
+	if err != nil || !avatar.ValidSize(size) {
+		app.notFound(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+
+	if version == 0 {
+		img, err := avatar.Identicon(id, size)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(img))
+		return
+	}
+
+	f, modified, err := app.files.Open(avatarKey(id, version, size))
+	if err != nil {
+		w.Header().Del("Cache-Control")
+		if errors.Is(err, filestore.ErrNotFound) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+	defer f.Close()
+
+	http.ServeContent(w, r, "", modified, f)
+}
+
+func (app *application) accountAvatarPost(w http.ResponseWriter, r *http.Request) {
+	// Allow a little extra for the rest of the multipart body.
+	// 为 multipart 请求体的其他部分多留一点空间
+	r.Body = http.MaxBytesReader(w, r.Body, maxAvatarUpload+64<<10)
+
+	err := r.ParseMultipartForm(maxAvatarUpload)
+	if err != nil {
+		app.avatarError(w, r, "Please choose a picture no larger than 5MB.")
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, _, err := r.FormFile("avatar")
+	if err != nil {
+		app.avatarError(w, r, "Please choose a picture to upload.")
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// Don't trust the file name or the browser's idea of the content type;
+	// look at the data. Decoding it below is the real check.
+	// 不要相信文件名或浏览器提供的类型，检查数据本身。下面的解码才是真正的检查
+	switch http.DetectContentType(data) {
+	case "image/png", "image/jpeg", "image/gif":
+	default:
+		app.avatarError(w, r, "Your avatar must be a PNG, JPEG or GIF picture.")
+		return
+	}
+
+	images, err := avatar.Process(bytes.NewReader(data))
+	if err != nil {
+		switch {
+		case errors.Is(err, avatar.ErrUnsupportedFormat):
+			app.avatarError(w, r, "We couldn't read that picture. Please try a PNG, JPEG or GIF.")
+		case errors.Is(err, avatar.ErrTooLarge):
+			app.avatarError(w, r, "That picture is too big. Please use one no more than 4096 pixels across.")
+		default:
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	user := app.authenticatedUser(r)
+	version := time.Now().UnixMilli()
+
+	for size, img := range images {
+		err = app.files.Put(avatarKey(user.ID, version, size), img)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+
+	err = app.users.SetAvatarVersion(r.Context(), user.ID, version)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	err = app.deleteAvatarFiles(user.ID, user.AvatarVersion)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Your avatar has been updated.")
+	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+}
+
+// avatarError tells the user why their upload was refused.
+func (app *application) avatarError(w http.ResponseWriter, r *http.Request, message string) {
+	app.sessionManager.Put(r.Context(), "flash", message)
+	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+}
+
+func (app *application) accountAvatarDeletePost(w http.ResponseWriter, r *http.Request) {
+	user := app.authenticatedUser(r)
+
+	err := app.users.SetAvatarVersion(r.Context(), user.ID, 0)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	err = app.deleteAvatarFiles(user.ID, user.AvatarVersion)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Your avatar has been removed.")
+	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+}
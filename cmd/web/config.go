@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"net/url"
+	"snippetbox.ab.net/internal/config"
+	"strconv"
+)
+
+// validateConfig checks the settings which can be checked without doing
+// anything with them, so that a mistake is reported at startup along with
+// where the bad value came from.
+// 检查不需要实际使用就能检查的设置，这样错误在启动时就会报告，并且说明错误的值来自哪里
+func validateConfig(cfg *config.Config) error {
+	value := func(name string) string {
+		return flag.Lookup(name).Value.String()
+	}
+
+	switch value("registration") {
+	case registrationOpen, registrationInvite, registrationClosed:
+	default:
+		return cfg.Invalid("registration", "must be open, invite or closed")
+	}
+
+	switch value("store") {
+	case storeSQL:
+	case storeMemory:
+		if value("migrate") != "" {
+			return cfg.Invalid("migrate", "can't be used with -store=memory")
+		}
+		if value("replica-dsn") != "" {
+			return cfg.Invalid("replica-dsn", "can't be used with -store=memory")
+		}
+	default:
+		return cfg.Invalid("store", "must be sql or memory")
+	}
+
+	switch value("mailer") {
+	case "log", "file":
+	case "smtp":
+		port, _ := strconv.Atoi(value("smtp-port"))
+		if port < 1 || port > 65535 {
+			return cfg.Invalid("smtp-port", "must be between 1 and 65535")
+		}
+	default:
+		return cfg.Invalid("mailer", "must be log, file or smtp")
+	}
+
+	u, err := url.Parse(value("base-url"))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return cfg.Invalid("base-url", "must be an absolute http or https URL")
+	}
+
+	if value("oidc-issuer") != "" && value("oidc-client-id") == "" {
+		return cfg.Invalid("oidc-client-id", "is needed with -oidc-issuer")
+	}
+
+	if attempts, _ := strconv.Atoi(value("db-connect-attempts")); attempts < 1 {
+		return cfg.Invalid("db-connect-attempts", "must be at least 1")
+	}
+
+	return cfg.CheckNonNegative(
+		"idle-timeout", "read-timeout", "write-timeout", "shutdown-timeout",
+		"query-timeout", "replica-stickiness",
+		"db-max-open-conns", "db-max-idle-conns", "db-conn-max-lifetime", "db-conn-max-idle-time",
+		"snippet-cache-size", "snippet-cache-ttl",
+		"session-lifetime", "remember-me-lifetime", "remember-me-idle-timeout",
+		"invitations-per-user",
+	)
+}
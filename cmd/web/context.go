@@ -0,0 +1,8 @@
+package main
+
+// Define a custom contextKey type, so that the keys we store in the request
+// context can't collide with keys set by other packages.
+// 自定义 contextKey 类型，避免和其他包设置的 key 冲突
+type contextKey string
+
+const authenticatedUserContextKey = contextKey("authenticatedUser")
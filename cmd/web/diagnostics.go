@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"snippetbox.ab.net/internal/database"
+)
+
+// diagnostics is the JSON document served by adminDiagnostics. Durations
+// are written the way Go prints them, such as "1.5s".
+// adminDiagnostics 返回的 JSON 文档，时长按照 Go 的格式输出，比如 "1.5s"
+type diagnostics struct {
+	Database databaseDiagnostics  `json:"database"`
+	Replica  *databaseDiagnostics `json:"replica,omitempty"`
+}
+
+type databaseDiagnostics struct {
+	Dialect      string `json:"dialect"`
+	QueryTimeout string `json:"query_timeout"`
+
+	MaxOpenConnections int    `json:"max_open_connections"`
+	OpenConnections    int    `json:"open_connections"`
+	InUse              int    `json:"in_use"`
+	Idle               int    `json:"idle"`
+	WaitCount          int64  `json:"wait_count"`
+	WaitDuration       string `json:"wait_duration"`
+	MaxIdleClosed      int64  `json:"max_idle_closed"`
+	MaxIdleTimeClosed  int64  `json:"max_idle_time_closed"`
+	MaxLifetimeClosed  int64  `json:"max_lifetime_closed"`
+}
+
+// adminDiagnostics reports the state of the database connection pool, to
+// help tune the -db-* flags. A steadily growing wait count means requests
+// are queueing for connections.
+// 报告数据库连接池的状态，用于调整 -db-* 参数。wait_count 持续增长说明请求在排队等待连接
+func (app *application) adminDiagnostics(w http.ResponseWriter, r *http.Request) {
+	d := diagnostics{
+		Database: newDatabaseDiagnostics(app.db),
+	}
+	if app.replica != nil {
+		replica := newDatabaseDiagnostics(app.replica)
+		d.Replica = &replica
+	}
+
+	js, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(append(js, '\n'))
+}
+
+func newDatabaseDiagnostics(db *database.DB) databaseDiagnostics {
+	stats := db.Stats()
+
+	return databaseDiagnostics{
+		Dialect:      db.Dialect,
+		QueryTimeout: db.QueryTimeout.String(),
+
+		MaxOpenConnections: stats.MaxOpenConnections,
+		OpenConnections:    stats.OpenConnections,
+		InUse:              stats.InUse,
+		Idle:               stats.Idle,
+		WaitCount:          stats.WaitCount,
+		WaitDuration:       stats.WaitDuration.String(),
+		MaxIdleClosed:      stats.MaxIdleClosed,
+		MaxIdleTimeClosed:  stats.MaxIdleTimeClosed,
+		MaxLifetimeClosed:  stats.MaxLifetimeClosed,
+	}
+}
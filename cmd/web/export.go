@@ -0,0 +1,89 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"io"
+	"snippetbox.ab.net/internal/models"
+	"time"
+)
+
+// exportProfile and exportSnippet are the JSON shapes used in a data export.
+// They're kept separate from the models so that internal fields, like password
+// hashes, can never end up in an export by accident.
+// 数据导出使用的 JSON 结构，和 models 分开定义，避免密码哈希等内部字段被意外导出
+type exportProfile struct {
+	ID               int       `json:"id"`
+	Name             string    `json:"name"`
+	Handle           string    `json:"handle,omitempty"`
+	Email            string    `json:"email"`
+	EmailVerified    bool      `json:"email_verified"`
+	Role             string    `json:"role"`
+	TwoFactorEnabled bool      `json:"two_factor_enabled"`
+	Created          time.Time `json:"created"`
+}
+
+type exportSnippet struct {
+	ID      int       `json:"id"`
+	Title   string    `json:"title"`
+	Content string    `json:"content"`
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires"`
+}
+
+// writeDataExport writes a ZIP archive containing profile.json and
+// snippets.json to w.
+// 向 w 写入包含 profile.json 和 snippets.json 的 ZIP 文件
+func writeDataExport(w io.Writer, user *models.User, snippets []*models.Snippet) error {
+	zw := zip.NewWriter(w)
+
+	profile := exportProfile{
+		ID:               user.ID,
+		Name:             user.Name,
+		Handle:           user.Handle,
+		Email:            user.Email,
+		EmailVerified:    user.EmailVerified,
+		Role:             user.Role,
+		TwoFactorEnabled: user.TOTPEnabled(),
+		Created:          user.Created,
+	}
+
+	exported := make([]exportSnippet, len(snippets))
+	for i, s := range snippets {
+		exported[i] = exportSnippet{
+			ID:      s.ID,
+			Title:   s.Title,
+			Content: s.Content,
+			Created: s.Created,
+			Expires: s.Expires,
+		}
+	}
+
+	files := []struct {
+		name string
+		v    any
+	}{
+		{"profile.json", profile},
+		{"snippets.json", exported},
+	}
+
+	for _, f := range files {
+		fw, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     f.name,
+			Method:   zip.Deflate,
+			Modified: time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+
+		enc := json.NewEncoder(fw)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(f.v)
+		if err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
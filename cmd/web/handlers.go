@@ -1,20 +1,80 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/subtle"
 	"errors"
 	"fmt"
 	"github.com/julienschmidt/httprouter"
+	"github.com/skip2/go-qrcode"
+	"net"
 	"net/http"
+	"net/url"
+	"snippetbox.ab.net/internal/audit"
 	"snippetbox.ab.net/internal/models"
+	"snippetbox.ab.net/internal/oidc"
+	"snippetbox.ab.net/internal/totp"
 	"snippetbox.ab.net/internal/validator"
 	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// passwordResetTTL is how long a password reset link stays valid for.
+	// 密码重置链接的有效期
+	passwordResetTTL = time.Hour
+
+	// verificationTTL is how long an email verification link stays valid
+	// for, and verificationResendInterval is the minimum time between two
+	// verification emails for the same account.
+	// 邮箱验证链接的有效期，以及两次发送验证邮件之间的最小间隔
+	verificationTTL            = 3 * 24 * time.Hour
+	verificationResendInterval = 5 * time.Minute
+
+	// minPasswordScore is the lowest strength score (0-4) a new password
+	// can have.
+	// 新密码的最低强度分数（0-4）
+	minPasswordScore = 2
+
+	// maxTwoFactorAttempts is how many wrong codes can be entered before
+	// the user has to start the login again, and recoveryCodeCount is how
+	// many recovery codes are issued when two-factor authentication is
+	// turned on.
+	// 两步验证允许输错的次数，以及开启两步验证时生成的恢复码数量
+	maxTwoFactorAttempts = 5
+	recoveryCodeCount    = 10
+
+	// invitationTTL is how long a signup invitation stays valid for.
+	// 注册邀请的有效期
+	invitationTTL = 7 * 24 * time.Hour
+
+	// adminListLimit is how many rows the admin pages show at once.
+	// 管理页面每次显示的行数
+	adminListLimit = 50
+
+	// auditExportLimit caps how many events a CSV export of the audit log
+	// contains.
+	// 审计日志 CSV 导出的最大事件数
+	auditExportLimit = 10000
+
+	// reauthWindow is how long after confirming who they are with single
+	// sign-on a user can delete their account without their password.
+	// 用户通过单点登录确认身份之后，在这段时间内可以不输入密码删除账号
+	reauthWindow = 5 * time.Minute
+
+	// totpIssuer is the name authenticator apps show next to the account.
+	// 身份验证器应用中显示的服务名称
+	totpIssuer = "Snippetbox"
 )
 
 func (app *application) home(w http.ResponseWriter, r *http.Request) {
 	// Because httprouter matches the "/" path exactly, we can now remove the
 	// manual check of r.URL.Path != "/" from this handler.
 	// httpprouter 只匹配 ‘/’ 路由，所以现在移除对路径的判断
-	snippets, err := app.snippets.Latest()
+	snippets, err := app.snippets.Latest(r.Context())
 	if err != nil {
 		app.serverError(w, err)
 		return
@@ -41,7 +101,7 @@ func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	snippet, err := app.snippets.Get(id)
+	snippet, err := app.snippets.Get(r.Context(), id)
 	if err != nil {
 		if errors.Is(err, models.ErrNoRecord) {
 			app.notFound(w)
@@ -119,12 +179,17 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	id, err := app.snippets.Insert(form.Title, form.Content, form.Expires)
+	user := app.authenticatedUser(r)
+
+	id, err := app.snippets.Insert(r.Context(), user.ID, form.Title, form.Content, form.Expires)
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
 
+	app.audit(r, audit.SnippetCreate, user.ID, fmt.Sprintf("snippet:%d", id), form.Title)
+	app.wroteSnippets(r)
+
 	// Use the Put() method to add a string value ("Snippet successfully
 	// created!") and the corresponding key ("flash") to the session data.
 	// 向 session 数据中添加 flash 字段，内容为 Snippet successfully created!
@@ -134,16 +199,65 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 
 }
 
+// checkHandle validates a (normalized) handle for a user to take.
+// 校验用户要使用的 handle（已经规范化过）
+func (app *application) checkHandle(v *validator.Validator, key, handle string) {
+	v.CheckField(validator.NotBlank(handle), key, "This field cannot be blank")
+	v.CheckField(validator.Matches(handle, validator.HandleRX), key, "This field must be 3-30 lower case letters, digits or underscores, starting with a letter")
+	v.CheckField(validator.NotReservedHandle(handle), key, "This handle is reserved")
+}
+
+// loginEmail returns the email address to log in with for what was typed
+// into the login form. A handle is looked up; if nobody has it, it's
+// returned as it is and will simply fail to match an account.
+// 返回登录表单输入内容对应的邮箱。handle 会被查找，如果没有人使用，原样返回，之后自然无法匹配到账号
+func (app *application) loginEmail(ctx context.Context, login string) (string, error) {
+	login = strings.TrimSpace(login)
+	if strings.Contains(login, "@") {
+		return login, nil
+	}
+
+	handle := validator.NormalizeHandle(login)
+
+	user, err := app.users.GetByHandle(ctx, handle)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			return handle, nil
+		}
+		return "", err
+	}
+
+	return user.Email, nil
+}
+
+// checkPasswordQuality rejects new passwords which have turned up in a data
+// breach or are too easy to guess, explaining why. userInputs are other
+// things the user typed into the form, like their name, which make a poor
+// password.
+// 拒绝出现在数据泄露中或者太容易被猜到的新密码，并说明原因。userInputs 是用户在表单中输入的其他内容，比如名字
+func (app *application) checkPasswordQuality(v *validator.Validator, key, password string, userInputs ...string) {
+	v.CheckField(validator.NotBreached(password, app.breachedPasswords), key,
+		"This password has appeared in a data breach and can't be used. Please choose a different one")
+
+	strength := validator.PasswordStrength(password, userInputs...)
+	v.CheckField(strength.Score >= minPasswordScore, key,
+		strings.TrimSpace("This password is too easy to guess. "+strength.Feedback()))
+}
+
 type userSignupForm struct {
 	Name                string `form:"name"`
 	Email               string `form:"email"`
 	Password            string `form:"password"`
+	Invitation          string `form:"invitation"`
+	Handle              string `form:"handle"`
 	validator.Validator `form:"-"`
 }
 
 func (app *application) userSignup(w http.ResponseWriter, r *http.Request) {
 	data := app.newTemplateData(r)
-	data.Form = userSignupForm{}
+	// Invitation links carry the code, so fill it in.
+	// 邀请链接中带有邀请码，自动填入
+	data.Form = userSignupForm{Invitation: r.URL.Query().Get("invite")}
 	app.render(w, http.StatusOK, "signup.tmpl", data)
 }
 
@@ -156,11 +270,28 @@ func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if app.registration == registrationClosed {
+		form.AddNonFieldError("Sorry, we aren't accepting new signups at the moment.")
+
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusForbidden, "signup.tmpl", data)
+		return
+	}
+
+	if app.registration == registrationInvite {
+		form.CheckField(validator.NotBlank(form.Invitation), "invitation", "You need an invitation to sign up")
+	}
+
+	form.Handle = validator.NormalizeHandle(form.Handle)
+
 	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+	app.checkHandle(&form.Validator, "handle", form.Handle)
 	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
 	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
 	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
 	form.CheckField(validator.MinChars(form.Password, 8), "password", "This field must be at least 8 characters long")
+	app.checkPasswordQuality(&form.Validator, "password", form.Password, form.Name, form.Handle, form.Email)
 
 	if !form.Valid() {
 		data := app.newTemplateData(r)
@@ -171,10 +302,23 @@ func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
 
 	// Try to create a new user record in the database. If the email already
 	// exists then add an error message to the form and re-display it.
-	err = app.users.Insert(form.Name, form.Email, form.Password)
+	// 在仅邀请模式下，同时使用邀请码
+	var id int
+	if app.registration == registrationInvite {
+		id, err = app.users.InsertInvited(r.Context(), form.Name, form.Handle, form.Email, form.Password, strings.TrimSpace(form.Invitation))
+	} else {
+		id, err = app.users.Insert(r.Context(), form.Name, form.Handle, form.Email, form.Password)
+	}
 	if err != nil {
-		if errors.Is(err, models.ErrDuplicateEmail) {
-			form.AddFieldError("email", "Email address is already in use")
+		if errors.Is(err, models.ErrDuplicateEmail) || errors.Is(err, models.ErrDuplicateHandle) || errors.Is(err, models.ErrInvalidInvitation) {
+			switch {
+			case errors.Is(err, models.ErrDuplicateEmail):
+				form.AddFieldError("email", "Email address is already in use")
+			case errors.Is(err, models.ErrDuplicateHandle):
+				form.AddFieldError("handle", "Handle is already taken")
+			default:
+				form.AddFieldError("invitation", "This invitation is invalid, has expired or has already been used")
+			}
 
 			data := app.newTemplateData(r)
 			data.Form = form
@@ -186,9 +330,24 @@ func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	detail := ""
+	if app.registration == registrationInvite {
+		detail = "invited"
+	}
+	app.audit(r, audit.Signup, id, form.Email, detail)
+
+	// Send the new user a link to verify their email address. Until they
+	// follow it they can log in, but not publish snippets.
+	// 给新用户发送邮箱验证链接，验证之前可以登录但不能发布 snippet
+	err = app.sendVerificationEmail(&models.User{ID: id, Name: form.Name, Email: form.Email})
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
 	// Otherwise add a confirmation flash message to the session confirming that
 	// their signup worked.
-	app.sessionManager.Put(r.Context(), "flash", "Your signup was successful. Please log in.")
+	app.sessionManager.Put(r.Context(), "flash", "Your signup was successful. We've sent you an email to verify your address. Please log in.")
 
 	// And redirect the user to the login page.
 	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
@@ -196,8 +355,9 @@ func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
 
 // Create a new userLoginForm struct.
 type userLoginForm struct {
-	Email               string `form:"email"`
+	Login               string `form:"login"` // email address or handle
 	Password            string `form:"password"`
+	RememberMe          bool   `form:"rememberMe"`
 	validator.Validator `form:"-"`
 }
 
@@ -217,11 +377,15 @@ func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Do some validation checks on the form. We check that both email and
-	// password are provided, and also check the format of the email address as
-	// a UX-nicety (in case the user makes a typo).
-	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
-	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
+	// Do some validation checks on the form. We check that both the email
+	// address or handle and the password are provided, and also check the
+	// format of an email address as a UX-nicety (in case the user makes a
+	// typo).
+	// 用户可以用邮箱或者 handle 登录，包含 @ 的按邮箱处理
+	form.CheckField(validator.NotBlank(form.Login), "login", "This field cannot be blank")
+	if strings.Contains(form.Login, "@") {
+		form.CheckField(validator.Matches(form.Login, validator.EmailRX), "login", "This field must be a valid email address")
+	}
 	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
 
 	if !form.Valid() {
@@ -231,16 +395,73 @@ func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Everything from here on, including throttling, is keyed by email
+	// address, so that switching between email and handle doesn't buy any
+	// extra guesses.
+	// 之后的所有操作（包括限流）都使用邮箱，这样在邮箱和 handle 之间切换不能获得额外的尝试次数
+	email, err := app.loginEmail(r.Context(), form.Login)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// Refuse to even check the password if there have been too many recent
+	// failures for this account or from this client.
+	// 如果这个账号或者客户端最近失败的次数太多，直接拒绝，不检查密码
+	ip := clientIP(r)
+
+	wait, err := app.loginWait(email, ip)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if wait > 0 {
+		err = app.loginAttempts.Record(email, ip, models.LoginBlocked)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		app.audit(r, audit.LoginFailure, 0, email, "throttled")
+
+		form.AddNonFieldError(fmt.Sprintf("Too many failed login attempts. Please try again in %s.", humanDuration(wait)))
+
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusTooManyRequests, "login.tmpl", data)
+		return
+	}
+
 	// Check whether the credentials are valid. If they're not, add a generic
 	// non-field error message and re-display the login page.
-	id, err := app.users.Authenticate(form.Email, form.Password)
+	id, err := app.users.Authenticate(r.Context(), email, form.Password)
 	if err != nil {
 		if errors.Is(err, models.ErrInvalidCredentials) {
-			form.AddNonFieldError("Email or password is incorrect")
+			err = app.loginAttempts.Record(email, ip, models.LoginFailure)
+			if err != nil {
+				app.serverError(w, err)
+				return
+			}
+			app.audit(r, audit.LoginFailure, 0, email, "invalid credentials")
+
+			form.AddNonFieldError("Email, handle or password is incorrect")
 
 			data := app.newTemplateData(r)
 			data.Form = form
 			app.render(w, http.StatusUnprocessableEntity, "login.tmpl", data)
+		} else if errors.Is(err, models.ErrAccountDisabled) {
+			err = app.loginAttempts.Record(email, ip, models.LoginDisabled)
+			if err != nil {
+				app.serverError(w, err)
+				return
+			}
+			app.audit(r, audit.LoginFailure, 0, email, "account disabled")
+
+			form.AddNonFieldError("This account has been disabled")
+
+			data := app.newTemplateData(r)
+			data.Form = form
+			app.render(w, http.StatusForbidden, "login.tmpl", data)
 		} else {
 			app.serverError(w, err)
 		}
@@ -257,23 +478,1730 @@ func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	user, err := app.users.Get(r.Context(), id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// If the user has turned on two-factor authentication the password alone
+	// isn't enough. Remember who they claim to be, but don't log them in
+	// until they've entered a code as well.
+	// 如果用户开启了两步验证，仅有密码是不够的，先记录待验证的用户，输入验证码后才算登录
+	if user.TOTPEnabled() {
+		app.sessionManager.Put(r.Context(), "pendingTwoFactorUserID", id)
+		app.sessionManager.Put(r.Context(), "pendingRememberMe", form.RememberMe)
+		app.sessionManager.Put(r.Context(), "pendingLoginMethod", "password")
+		http.Redirect(w, r, "/user/login/2fa", http.StatusSeeOther)
+		return
+	}
+
+	// Only a complete login clears the account's failures, so it's recorded
+	// here rather than as soon as the password matches, or a second factor
+	// could be guessed without ever being locked out.
+	// 只有完整的登录才会清除账号的失败记录，所以在这里记录，而不是密码正确时就记录，
+	// 否则猜测第二因素时永远不会被锁定
+	err = app.loginAttempts.Record(email, ip, models.LoginSuccess)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
 	// Add the ID of the current user to the session, so that they are now
 	// 'logged in'.
-	app.sessionManager.Put(r.Context(), "authenticatedUserID", id)
+	err = app.logIn(r, id, form.RememberMe)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.audit(r, audit.LoginSuccess, id, user.Email, "password")
 
 	// Redirect the user to the create snippet page.
 	http.Redirect(w, r, "/snippet/create", http.StatusSeeOther)
 }
 
+type userLoginTwoFactorForm struct {
+	Code                string `form:"code"`
+	validator.Validator `form:"-"`
+}
+
+func (app *application) userLoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	if !app.sessionManager.Exists(r.Context(), "pendingTwoFactorUserID") {
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = userLoginTwoFactorForm{}
+	app.render(w, http.StatusOK, "login_2fa.tmpl", data)
+}
+
+func (app *application) userLoginTwoFactorPost(w http.ResponseWriter, r *http.Request) {
+	id := app.sessionManager.GetInt(r.Context(), "pendingTwoFactorUserID")
+	if id == 0 {
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	var form userLoginTwoFactorForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Code), "code", "This field cannot be blank")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "login_2fa.tmpl", data)
+		return
+	}
+
+	user, err := app.users.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.sessionManager.Remove(r.Context(), "pendingTwoFactorUserID")
+			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	// The session's own counter is reset by entering the password again, so
+	// wrong codes also count as failed logins for the account and the
+	// client, and are throttled the same way as wrong passwords.
+	// session 中的计数在重新输入密码后会被清零，所以错误的验证码也按照账号和客户端记为登录失败，
+	// 并且和错误的密码一样限流
+	ip := clientIP(r)
+
+	wait, err := app.loginWait(user.Email, ip)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if wait > 0 {
+		err = app.loginAttempts.Record(user.Email, ip, models.LoginBlocked)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		app.audit(r, audit.LoginFailure, 0, user.Email, "throttled")
+
+		form.AddNonFieldError(fmt.Sprintf("Too many failed login attempts. Please try again in %s.", humanDuration(wait)))
+
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusTooManyRequests, "login_2fa.tmpl", data)
+		return
+	}
+
+	ok, usedRecoveryCode, err := app.checkSecondFactor(r.Context(), user, form.Code)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if !ok {
+		err = app.loginAttempts.Record(user.Email, ip, models.LoginFailure)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		// Only allow a handful of guesses before the password has to be
+		// entered again, otherwise the code could simply be brute forced.
+		// 只允许猜几次，超过次数需要重新输入密码，否则验证码可能被暴力破解
+		attempts := app.sessionManager.GetInt(r.Context(), "twoFactorAttempts") + 1
+		if attempts >= maxTwoFactorAttempts {
+			app.sessionManager.Remove(r.Context(), "pendingTwoFactorUserID")
+			app.sessionManager.Remove(r.Context(), "pendingRememberMe")
+			app.sessionManager.Remove(r.Context(), "pendingLoginMethod")
+			app.sessionManager.Remove(r.Context(), "twoFactorAttempts")
+			app.sessionManager.Put(r.Context(), "flash", "Too many incorrect codes. Please log in again.")
+			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+			return
+		}
+		app.sessionManager.Put(r.Context(), "twoFactorAttempts", attempts)
+		app.audit(r, audit.LoginFailure, 0, user.Email, "incorrect second factor")
+
+		form.AddNonFieldError("That code is incorrect or has already been used")
+
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "login_2fa.tmpl", data)
+		return
+	}
+
+	// Both factors have been checked, so this is where the user actually
+	// logs in. Renew the session token again because the privilege level has
+	// changed.
+	// 两步验证都通过了，这里才真正登录，权限发生变化所以再次更新 session token
+	err = app.sessionManager.RenewToken(r.Context())
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	err = app.loginAttempts.Record(user.Email, ip, models.LoginSuccess)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	rememberMe := app.sessionManager.PopBool(r.Context(), "pendingRememberMe")
+	method := app.sessionManager.PopString(r.Context(), "pendingLoginMethod")
+	app.sessionManager.Remove(r.Context(), "pendingTwoFactorUserID")
+	app.sessionManager.Remove(r.Context(), "twoFactorAttempts")
+
+	err = app.logIn(r, user.ID, rememberMe)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if usedRecoveryCode {
+		app.audit(r, audit.LoginSuccess, user.ID, user.Email, method+" and recovery code")
+	} else {
+		app.audit(r, audit.LoginSuccess, user.ID, user.Email, method+" and TOTP")
+	}
+
+	if usedRecoveryCode {
+		remaining, err := app.recoveryCodes.Remaining(user.ID)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("You logged in with a recovery code. You have %d recovery codes left.", remaining))
+	}
+
+	http.Redirect(w, r, "/snippet/create", http.StatusSeeOther)
+}
+
+// checkSecondFactor accepts either a current TOTP code or one of the user's
+// unused recovery codes. The second return value reports whether a recovery
+// code was used up.
+// 接受当前的 TOTP 验证码或者一个未使用的恢复码，第二个返回值表示是否使用了恢复码
+func (app *application) checkSecondFactor(ctx context.Context, user *models.User, code string) (bool, bool, error) {
+	step, ok := totp.Validate(user.TOTPSecret, code, time.Now(), 1)
+	if ok {
+		// Refuse codes that have already been used to log in once.
+		// 拒绝已经用于登录过的验证码
+		fresh, err := app.users.UseTOTPStep(ctx, user.ID, step)
+		return fresh, false, err
+	}
+
+	err := app.recoveryCodes.Use(user.ID, code)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			return false, false, nil
+		}
+		return false, false, err
+	}
+
+	return true, true, nil
+}
+
 func (app *application) userLogoutPost(w http.ResponseWriter, r *http.Request) {
-	err := app.sessionManager.RenewToken(r.Context())
+	// Remove the session from the list of active sessions before the token
+	// changes.
+	// 在 token 改变之前，从活跃会话列表中删除当前会话
+	err := app.sessions.Delete(app.sessionManager.Token(r.Context()))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if user := app.authenticatedUser(r); user != nil {
+		app.audit(r, audit.Logout, user.ID, user.Email, "")
+	}
+
+	err = app.sessionManager.RenewToken(r.Context())
 	if err != nil {
 		app.serverError(w, err)
+		return
 	}
 	// 从 session 数据中移除 authenticatedUserID， 实现真正的登出
 	app.sessionManager.Remove(r.Context(), "authenticatedUserID")
+	app.sessionManager.Remove(r.Context(), "loginExpires")
+	// The anonymous session left behind shouldn't outlive the browser.
+	// 登出后剩下的匿名会话不应该在浏览器关闭后继续保留
+	app.sessionManager.RememberMe(r.Context(), false)
 	// 增加一条 flash 消息确认当前用户已经登出
 	app.sessionManager.Put(r.Context(), "flash", "You've been logged out successfully!")
 	// 重定向到主页
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
+
+type userForgotPasswordForm struct {
+	Email               string `form:"email"`
+	validator.Validator `form:"-"`
+}
+
+func (app *application) userForgotPassword(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = userForgotPasswordForm{}
+	app.render(w, http.StatusOK, "forgot.tmpl", data)
+}
+
+func (app *application) userForgotPasswordPost(w http.ResponseWriter, r *http.Request) {
+	var form userForgotPasswordForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
+	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "forgot.tmpl", data)
+		return
+	}
+
+	// Only send the email if the account exists, but always respond in the
+	// same way so that the form can't be used to find out which email
+	// addresses are registered.
+	// 只有账号存在时才发送邮件，但响应总是一样的，避免泄露哪些邮箱已经注册
+	user, err := app.users.GetByEmail(r.Context(), form.Email)
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		app.serverError(w, err)
+		return
+	}
+
+	if user != nil {
+		token, err := app.tokens.New(user.ID, passwordResetTTL, models.ScopePasswordReset)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		// Send the email in a background goroutine so that a slow mail server
+		// doesn't hold up the response.
+		// 在后台 goroutine 中发送邮件，避免邮件服务器太慢拖慢响应
+		app.background(func() {
+			data := map[string]any{
+				"Name":     user.Name,
+				"ResetURL": fmt.Sprintf("%s/user/password/reset/%s", app.baseURL, token),
+				"TTL":      "1 hour",
+			}
+
+			err := app.mailer.Send(user.Email, "password_reset.tmpl", data)
+			if err != nil {
+				app.errorLog.Print(err)
+			}
+		})
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "If an account exists for that email address, we've sent it a link to reset the password.")
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
+
+type userResetPasswordForm struct {
+	NewPassword             string `form:"newPassword"`
+	NewPasswordConfirmation string `form:"newPasswordConfirmation"`
+	Token                   string `form:"-"`
+	validator.Validator     `form:"-"`
+}
+
+func (app *application) userResetPassword(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	token := params.ByName("token")
+
+	// Check the token up front so that the user doesn't type a new password
+	// into a form which is going to be rejected anyway.
+	// 提前检查 token，避免用户填写了表单后才发现链接已失效
+	_, err := app.tokens.GetUserID(models.ScopePasswordReset, token)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.sessionManager.Put(r.Context(), "flash", "That password reset link is invalid or has expired. Please request a new one.")
+			http.Redirect(w, r, "/user/password/forgot", http.StatusSeeOther)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = userResetPasswordForm{Token: token}
+	app.render(w, http.StatusOK, "reset.tmpl", data)
+}
+
+func (app *application) userResetPasswordPost(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	form := userResetPasswordForm{Token: params.ByName("token")}
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.NewPassword), "newPassword", "This field cannot be blank")
+	form.CheckField(validator.MinChars(form.NewPassword, 8), "newPassword", "This field must be at least 8 characters long")
+	app.checkPasswordQuality(&form.Validator, "newPassword", form.NewPassword)
+	form.CheckField(form.NewPassword == form.NewPasswordConfirmation, "newPasswordConfirmation", "Passwords do not match")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "reset.tmpl", data)
+		return
+	}
+
+	// Consume the token only once the form is valid, so a typo doesn't burn
+	// the link.
+	// 表单验证通过后才消耗 token，避免输入错误导致链接失效
+	userID, err := app.tokens.Consume(models.ScopePasswordReset, form.Token)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.sessionManager.Put(r.Context(), "flash", "That password reset link is invalid or has expired. Please request a new one.")
+			http.Redirect(w, r, "/user/password/forgot", http.StatusSeeOther)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	err = app.users.UpdatePassword(r.Context(), userID, form.NewPassword)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// Whoever follows a reset link isn't logged in, but they've proved they
+	// own the account, so it's recorded as the account owner's doing.
+	// 使用重置链接的人没有登录，但已经证明了自己拥有该账号，所以记为账号所有者的操作
+	app.audit(r, audit.PasswordChange, userID, fmt.Sprintf("user:%d", userID), "reset link")
+
+	// Any other outstanding reset links for the account are no longer needed.
+	// 删除该账号其他未使用的重置链接
+	err = app.tokens.DeleteAllForUser(models.ScopePasswordReset, userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// Whoever knew the old password may still be logged in somewhere, so log
+	// the account out everywhere.
+	// 知道旧密码的人可能还登录着，所以注销该账号的所有会话
+	err = app.revokeSessions(userID, "")
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Your password has been reset. Please log in.")
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
+
+// sendVerificationEmail issues a new verification token for the user and
+// emails them the link in the background.
+// 为用户签发新的验证 token，并在后台发送验证邮件
+func (app *application) sendVerificationEmail(user *models.User) error {
+	token, err := app.tokens.New(user.ID, verificationTTL, models.ScopeVerification)
+	if err != nil {
+		return err
+	}
+
+	app.background(func() {
+		data := map[string]any{
+			"Name":      user.Name,
+			"VerifyURL": fmt.Sprintf("%s/user/verify/%s", app.baseURL, token),
+			"TTL":       "3 days",
+		}
+
+		err := app.mailer.Send(user.Email, "user_verification.tmpl", data)
+		if err != nil {
+			app.errorLog.Print(err)
+		}
+	})
+
+	return nil
+}
+
+func (app *application) userVerify(w http.ResponseWriter, r *http.Request) {
+	// There's nothing to do here for users who are already verified.
+	// 已经验证过的用户直接跳转
+	if app.authenticatedUser(r).EmailVerified {
+		http.Redirect(w, r, "/snippet/create", http.StatusSeeOther)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	app.render(w, http.StatusOK, "verify.tmpl", data)
+}
+
+func (app *application) userVerifyEmail(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	userID, err := app.tokens.Consume(models.ScopeVerification, params.ByName("token"))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.sessionManager.Put(r.Context(), "flash", "That verification link is invalid or has expired. Log in to request a new one.")
+			http.Redirect(w, r, "/", http.StatusSeeOther)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	err = app.users.SetEmailVerified(r.Context(), userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// Any other verification links that were sent out are now pointless.
+	// 其他已发出的验证链接都不再需要
+	err = app.tokens.DeleteAllForUser(models.ScopeVerification, userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Thanks, your email address has been verified!")
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (app *application) userVerifyResendPost(w http.ResponseWriter, r *http.Request) {
+	user := app.authenticatedUser(r)
+
+	if user.EmailVerified {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	// Throttle resends so the form can't be used to flood someone's inbox.
+	// 限制重新发送的频率，防止被用来轰炸别人的邮箱
+	last, err := app.tokens.LatestCreated(models.ScopeVerification, user.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if time.Since(last) < verificationResendInterval {
+		app.sessionManager.Put(r.Context(), "flash", "We've sent you a verification email recently. Please wait a few minutes before asking for another one.")
+		http.Redirect(w, r, "/user/verify", http.StatusSeeOther)
+		return
+	}
+
+	err = app.sendVerificationEmail(user)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("We've sent a new verification link to %s.", user.Email))
+	http.Redirect(w, r, "/user/verify", http.StatusSeeOther)
+}
+
+func (app *application) accountView(w http.ResponseWriter, r *http.Request) {
+	user := app.authenticatedUser(r)
+
+	data := app.newTemplateData(r)
+	var err error
+
+	if user.TOTPEnabled() {
+		data.RecoveryCodesRemaining, err = app.recoveryCodes.Remaining(user.ID)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+
+	sessions, err := app.sessions.ListForUser(user.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	data.Sessions = sessions
+	data.CurrentSessionToken = app.sessionManager.Token(r.Context())
+
+	app.render(w, http.StatusOK, "account.tmpl", data)
+}
+
+type accountTOTPEnableForm struct {
+	Code                string `form:"code"`
+	Secret              string `form:"-"`
+	URI                 string `form:"-"`
+	validator.Validator `form:"-"`
+}
+
+// totpEnrollment returns the secret being enrolled for this session,
+// generating a new one the first time. The secret is only saved against the
+// user once they've proved their authenticator app can produce codes for it.
+// 返回当前 session 中正在登记的密钥，第一次访问时生成，只有用户成功输入验证码后才会保存到用户记录中
+func (app *application) totpEnrollment(r *http.Request) (string, error) {
+	secret := app.sessionManager.GetString(r.Context(), "totpEnrollSecret")
+	if secret != "" {
+		return secret, nil
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return "", err
+	}
+
+	app.sessionManager.Put(r.Context(), "totpEnrollSecret", secret)
+	return secret, nil
+}
+
+func (app *application) accountTOTPEnable(w http.ResponseWriter, r *http.Request) {
+	user := app.authenticatedUser(r)
+	if user.TOTPEnabled() {
+		http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+		return
+	}
+
+	secret, err := app.totpEnrollment(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = accountTOTPEnableForm{
+		Secret: secret,
+		URI:    totp.URI(totpIssuer, user.Email, secret),
+	}
+	app.render(w, http.StatusOK, "2fa_enable.tmpl", data)
+}
+
+func (app *application) accountTOTPQRCode(w http.ResponseWriter, r *http.Request) {
+	secret := app.sessionManager.GetString(r.Context(), "totpEnrollSecret")
+	if secret == "" {
+		app.notFound(w)
+		return
+	}
+
+	// Generate the QR code here rather than in the browser, so that the secret
+	// never has to be handed to a third-party service.
+	// 在服务端生成二维码，避免把密钥交给第三方服务
+	png, err := qrcode.Encode(totp.URI(totpIssuer, app.authenticatedUser(r).Email, secret), qrcode.Medium, 256)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Write(png)
+}
+
+func (app *application) accountTOTPEnablePost(w http.ResponseWriter, r *http.Request) {
+	user := app.authenticatedUser(r)
+	if user.TOTPEnabled() {
+		http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+		return
+	}
+
+	secret := app.sessionManager.GetString(r.Context(), "totpEnrollSecret")
+	if secret == "" {
+		http.Redirect(w, r, "/account/2fa/enable", http.StatusSeeOther)
+		return
+	}
+
+	form := accountTOTPEnableForm{
+		Secret: secret,
+		URI:    totp.URI(totpIssuer, user.Email, secret),
+	}
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Code), "code", "This field cannot be blank")
+
+	step, ok := totp.Validate(secret, form.Code, time.Now(), 1)
+	if form.Valid() && !ok {
+		form.AddFieldError("code", "That code is incorrect. Check the time on your device is correct and try again")
+	}
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "2fa_enable.tmpl", data)
+		return
+	}
+
+	err = app.users.EnableTOTP(r.Context(), user.ID, secret)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// The code used to confirm enrollment shouldn't also work for a login.
+	// 用于确认登记的验证码不能再用于登录
+	_, err = app.users.UseTOTPStep(r.Context(), user.ID, step)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Remove(r.Context(), "totpEnrollSecret")
+	app.audit(r, audit.TwoFactorEnable, user.ID, user.Email, "")
+
+	codes, err := app.recoveryCodes.Generate(user.ID, recoveryCodeCount)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// The recovery codes are rendered directly rather than after a redirect,
+	// because this is the only time the plaintext codes are available.
+	// 直接渲染恢复码而不是重定向，因为只有这一次能拿到明文恢复码
+	data := app.newTemplateData(r)
+	data.RecoveryCodes = codes
+	app.render(w, http.StatusOK, "2fa_recovery.tmpl", data)
+}
+
+type accountPasswordConfirmForm struct {
+	Password            string `form:"password"`
+	validator.Validator `form:"-"`
+}
+
+// confirmPassword decodes an accountPasswordConfirmForm and checks the
+// password against the logged in user. Sensitive account changes ask for the
+// password again, in case someone else is using an unattended session.
+// 敏感的账号操作需要再次输入密码，防止别人使用无人看管的会话
+func (app *application) confirmPassword(r *http.Request) (bool, error) {
+	var form accountPasswordConfirmForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = app.users.Authenticate(r.Context(), app.authenticatedUser(r).Email, form.Password)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (app *application) accountTOTPDisablePost(w http.ResponseWriter, r *http.Request) {
+	ok, err := app.confirmPassword(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if !ok {
+		app.sessionManager.Put(r.Context(), "flash", "Your password was incorrect, so two-factor authentication is still on.")
+		http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+		return
+	}
+
+	user := app.authenticatedUser(r)
+
+	err = app.users.DisableTOTP(r.Context(), user.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	err = app.recoveryCodes.DeleteAllForUser(user.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.audit(r, audit.TwoFactorDisable, user.ID, user.Email, "")
+
+	app.sessionManager.Put(r.Context(), "flash", "Two-factor authentication has been turned off.")
+	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+}
+
+func (app *application) accountRecoveryCodesPost(w http.ResponseWriter, r *http.Request) {
+	user := app.authenticatedUser(r)
+	if !user.TOTPEnabled() {
+		http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+		return
+	}
+
+	ok, err := app.confirmPassword(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if !ok {
+		app.sessionManager.Put(r.Context(), "flash", "Your password was incorrect, so your recovery codes haven't changed.")
+		http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+		return
+	}
+
+	codes, err := app.recoveryCodes.Generate(user.ID, recoveryCodeCount)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.RecoveryCodes = codes
+	app.render(w, http.StatusOK, "2fa_recovery.tmpl", data)
+}
+
+func (app *application) accountSessionDeletePost(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	user := app.authenticatedUser(r)
+
+	token, err := app.sessions.DeleteForUser(user.ID, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.audit(r, audit.SessionRevoke, user.ID, user.Email, fmt.Sprintf("session %d", id))
+
+	// Logging out the current device is just a normal logout.
+	// 注销当前设备就是普通的登出
+	if token == app.sessionManager.Token(r.Context()) {
+		err = app.sessionManager.Destroy(r.Context())
+		if err != nil {
+			app.serverError(w, err)
+		}
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	err = app.sessionManager.Store.Delete(token)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "That device has been logged out.")
+	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+}
+
+func (app *application) accountSessionsRevokeOthersPost(w http.ResponseWriter, r *http.Request) {
+	user := app.authenticatedUser(r)
+
+	err := app.revokeSessions(user.ID, app.sessionManager.Token(r.Context()))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.audit(r, audit.SessionRevoke, user.ID, user.Email, "all other sessions")
+
+	app.sessionManager.Put(r.Context(), "flash", "All your other devices have been logged out.")
+	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+}
+
+func (app *application) adminHome(w http.ResponseWriter, r *http.Request) {
+	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+}
+
+func (app *application) adminUsers(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+
+	users, err := app.users.Search(r.Context(), q, adminListLimit)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Users = users
+	data.Query = q
+	app.render(w, http.StatusOK, "admin_users.tmpl", data)
+}
+
+func (app *application) adminUserDisablePost(w http.ResponseWriter, r *http.Request) {
+	app.adminSetUserDisabled(w, r, true)
+}
+
+func (app *application) adminUserEnablePost(w http.ResponseWriter, r *http.Request) {
+	app.adminSetUserDisabled(w, r, false)
+}
+
+// adminSetUserDisabled disables or re-enables the account in the URL.
+// Disabling an account also logs it out everywhere.
+// 禁用或重新启用 URL 中指定的账号，禁用账号时同时注销它的所有会话
+func (app *application) adminSetUserDisabled(w http.ResponseWriter, r *http.Request, disabled bool) {
+	params := httprouter.ParamsFromContext(r.Context())
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	// Don't let administrators lock themselves out.
+	// 不允许管理员禁用自己
+	if disabled && id == app.authenticatedUser(r).ID {
+		app.sessionManager.Put(r.Context(), "flash", "You can't disable your own account.")
+		http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+		return
+	}
+
+	err = app.users.SetDisabled(r.Context(), id, disabled)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	admin := app.authenticatedUser(r)
+	subject := fmt.Sprintf("user:%d", id)
+
+	if disabled {
+		err = app.revokeSessions(id, "")
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		app.audit(r, audit.AccountDisable, admin.ID, subject, "")
+		app.sessionManager.Put(r.Context(), "flash", "The account has been disabled.")
+	} else {
+		app.audit(r, audit.AccountEnable, admin.ID, subject, "")
+		app.sessionManager.Put(r.Context(), "flash", "The account has been enabled.")
+	}
+
+	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+}
+
+func (app *application) adminSnippets(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+
+	snippets, err := app.snippets.Search(r.Context(), q, adminListLimit)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippets = snippets
+	data.Query = q
+	app.render(w, http.StatusOK, "admin_snippets.tmpl", data)
+}
+
+func (app *application) adminSnippetDeletePost(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	err = app.snippets.Delete(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.audit(r, audit.SnippetDelete, app.authenticatedUser(r).ID, fmt.Sprintf("snippet:%d", id), "removed by administrator")
+	app.wroteSnippets(r)
+
+	app.sessionManager.Put(r.Context(), "flash", "The snippet has been removed.")
+	http.Redirect(w, r, "/admin/snippets", http.StatusSeeOther)
+}
+
+func (app *application) adminLogins(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+
+	attempts, err := app.loginAttempts.Latest(q, adminListLimit)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.LoginAttempts = attempts
+	data.Query = q
+	app.render(w, http.StatusOK, "admin_logins.tmpl", data)
+}
+
+type auditFilterForm struct {
+	Action              string `form:"action"`
+	Actor               string `form:"actor"` // user ID, email address or handle
+	IP                  string `form:"ip"`
+	From                string `form:"from"`
+	To                  string `form:"to"`
+	validator.Validator `form:"-"`
+}
+
+// auditFilter decodes and checks the audit log filters in the query string.
+// Dates are whole days in UTC, and both ends are included.
+// 解析并校验查询字符串中的审计日志筛选条件，日期按 UTC 整天计算，包含两端
+func (app *application) auditFilter(r *http.Request) (auditFilterForm, audit.Filter, error) {
+	var form auditFilterForm
+	var filter audit.Filter
+
+	err := app.formDecoder.Decode(&form, r.URL.Query())
+	if err != nil {
+		form.AddNonFieldError("Those filters couldn't be understood")
+		return form, filter, nil
+	}
+
+	form.Actor = strings.TrimSpace(form.Actor)
+	form.IP = strings.TrimSpace(form.IP)
+
+	if form.Action != "" {
+		form.CheckField(validator.PermittedString(form.Action, audit.Actions...), "action", "This isn't a known action")
+		filter.Action = form.Action
+	}
+
+	if form.Actor != "" {
+		id, err := app.auditActorID(r.Context(), form.Actor)
+		if err != nil {
+			return form, filter, err
+		}
+		form.CheckField(id != 0, "actor", "No user matches this")
+		filter.ActorID = id
+	}
+
+	if form.IP != "" {
+		form.CheckField(net.ParseIP(form.IP) != nil, "ip", "This must be an IP address")
+		filter.IP = form.IP
+	}
+
+	if form.From != "" {
+		filter.From, err = time.Parse("2006-01-02", form.From)
+		form.CheckField(err == nil, "from", "This must be a date")
+	}
+	if form.To != "" {
+		filter.To, err = time.Parse("2006-01-02", form.To)
+		form.CheckField(err == nil, "to", "This must be a date")
+		filter.To = filter.To.AddDate(0, 0, 1)
+	}
+
+	return form, filter, nil
+}
+
+// auditActorID looks up the user an actor filter refers to, returning 0 if
+// there isn't one. Deleted users can still be found by ID.
+// 查找筛选条件中指定的用户，找不到时返回 0。已删除的用户仍然可以通过 ID 查找
+func (app *application) auditActorID(ctx context.Context, actor string) (int, error) {
+	if id, err := strconv.Atoi(actor); err == nil && id > 0 {
+		return id, nil
+	}
+
+	var user *models.User
+	var err error
+	if strings.Contains(strings.TrimPrefix(actor, "@"), "@") {
+		user, err = app.users.GetByEmail(ctx, actor)
+	} else {
+		user, err = app.users.GetByHandle(ctx, validator.NormalizeHandle(actor))
+	}
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return user.ID, nil
+}
+
+func (app *application) adminAudit(w http.ResponseWriter, r *http.Request) {
+	form, filter, err := app.auditFilter(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = form
+	data.AuditActions = audit.Actions
+
+	if !form.Valid() {
+		app.render(w, http.StatusUnprocessableEntity, "admin_audit.tmpl", data)
+		return
+	}
+
+	events, err := app.auditLog.List(filter, adminListLimit)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data.AuditEvents = events
+	data.AuditExportURL = "/admin/audit/export"
+	if r.URL.RawQuery != "" {
+		data.AuditExportURL += "?" + r.URL.Query().Encode()
+	}
+	app.render(w, http.StatusOK, "admin_audit.tmpl", data)
+}
+
+// adminAuditExport downloads the events matching the filters as CSV.
+// 把符合筛选条件的事件下载为 CSV
+func (app *application) adminAuditExport(w http.ResponseWriter, r *http.Request) {
+	form, filter, err := app.auditFilter(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if !form.Valid() {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	events, err := app.auditLog.List(filter, auditExportLimit)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	var buf bytes.Buffer
+	err = audit.WriteCSV(&buf, events)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	filename := fmt.Sprintf("audit-%s.csv", time.Now().UTC().Format("2006-01-02"))
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.Write(buf.Bytes())
+}
+
+// accountExport sends the user a ZIP file of their profile and snippets. It's
+// built in memory first, so that an error can still become a proper error
+// response.
+// 把用户的资料和 snippet 打包成 ZIP 文件下载，先在内存中生成，这样出错时仍然可以返回错误响应
+func (app *application) accountExport(w http.ResponseWriter, r *http.Request) {
+	user := app.authenticatedUser(r)
+
+	snippets, err := app.snippets.ListForUser(r.Context(), user.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	var buf bytes.Buffer
+	err = writeDataExport(&buf, user, snippets)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	filename := fmt.Sprintf("snippetbox-%s.zip", time.Now().UTC().Format("2006-01-02"))
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.Write(buf.Bytes())
+}
+
+type accountDeleteForm struct {
+	Password            string `form:"password"`
+	Snippets            string `form:"snippets"`
+	validator.Validator `form:"-"`
+}
+
+func (app *application) accountDelete(w http.ResponseWriter, r *http.Request) {
+	data, err := app.accountDeleteData(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	data.Form = accountDeleteForm{Snippets: "delete"}
+	app.render(w, http.StatusOK, "account_delete.tmpl", data)
+}
+
+// accountDeleteData returns the template data for the account deletion
+// page. Someone who signed up with single sign-on has never had a password,
+// so a user with a linked external account is offered to confirm who they
+// are with the provider instead.
+// 返回删除账号页面的模板数据。通过单点登录注册的用户从来没有密码，
+// 所以关联了外部账号的用户可以改为通过身份提供方确认身份
+func (app *application) accountDeleteData(r *http.Request) (*templateData, error) {
+	user := app.authenticatedUser(r)
+
+	linked, err := app.identities.Linked(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	data := app.newTemplateData(r)
+	data.IdentityLinked = linked
+	data.Reauthenticated = app.reauthenticated(r, user.ID)
+	return data, nil
+}
+
+// reauthenticated reports whether the user has confirmed who they are with
+// single sign-on in this session within the last reauthWindow.
+// 返回用户最近 reauthWindow 之内是否在这个会话中通过单点登录确认过身份
+func (app *application) reauthenticated(r *http.Request, userID int) bool {
+	if app.sessionManager.GetInt(r.Context(), "reauthenticatedUserID") != userID {
+		return false
+	}
+	at := app.sessionManager.GetTime(r.Context(), "reauthenticatedAt")
+	return time.Since(at) < reauthWindow
+}
+
+// accountDeleteSSO sends a user to the provider to log in again, so that
+// they can delete their account without a password. The callback sends them
+// back to the deletion page.
+// 把用户重定向到身份提供方重新登录，这样不需要密码就可以删除账号。回调会把用户送回删除账号页面
+func (app *application) accountDeleteSSO(w http.ResponseWriter, r *http.Request) {
+	if app.oidcProvider == nil {
+		app.notFound(w)
+		return
+	}
+
+	app.startOIDC(w, r, true)
+}
+
+func (app *application) accountDeletePost(w http.ResponseWriter, r *http.Request) {
+	var form accountDeleteForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	user := app.authenticatedUser(r)
+	reauthenticated := app.reauthenticated(r, user.ID)
+
+	if !reauthenticated {
+		form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
+	}
+	form.CheckField(validator.PermittedString(form.Snippets, "delete", "keep"), "snippets", "This field must equal delete or keep")
+
+	if form.Valid() && !reauthenticated {
+		_, err = app.users.Authenticate(r.Context(), user.Email, form.Password)
+		if err != nil {
+			if !errors.Is(err, models.ErrInvalidCredentials) {
+				app.serverError(w, err)
+				return
+			}
+			form.AddFieldError("password", "Your password was incorrect")
+		}
+	}
+
+	if !form.Valid() {
+		data, err := app.accountDeleteData(r)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "account_delete.tmpl", data)
+		return
+	}
+
+	err = app.users.Delete(r.Context(), user.ID, form.Snippets == "keep")
+	app.snippetsChanged()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	detail := form.Snippets + " snippets"
+	if reauthenticated {
+		detail += ", confirmed with sso"
+	}
+	app.audit(r, audit.AccountDelete, user.ID, fmt.Sprintf("user:%d", user.ID), detail)
+
+	err = app.deleteAvatarFiles(user.ID, user.AvatarVersion)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// The session data has already gone from the store, but destroying it
+	// stops the session manager from saving it again at the end of this
+	// request. A fresh session carries the goodbye message.
+	// session 数据已经从 store 中删除，但 Destroy 可以防止 session manager 在请求结束时重新保存它。新的 session 用来显示告别信息
+	err = app.sessionManager.Destroy(r.Context())
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Your account has been deleted. Goodbye!")
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// userLoginOIDC starts a single sign-on login by sending the user to the
+// provider. The state, nonce and PKCE verifier are kept in the session until
+// the provider sends the user back.
+// 开始单点登录，把用户重定向到身份提供方。state、nonce 和 PKCE verifier 保存在 session 中，直到用户被重定向回来
+func (app *application) userLoginOIDC(w http.ResponseWriter, r *http.Request) {
+	if app.oidcProvider == nil {
+		app.notFound(w)
+		return
+	}
+
+	app.startOIDC(w, r, false)
+}
+
+// startOIDC sends the user to the provider, either to log in or, if reauth
+// is true, to confirm who the logged in user is.
+// 把用户重定向到身份提供方，用于登录，或者在 reauth 为 true 时确认已登录用户的身份
+func (app *application) startOIDC(w http.ResponseWriter, r *http.Request, reauth bool) {
+	state, err := oidc.NewState()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	nonce, err := oidc.NewState()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	verifier, err := oidc.NewVerifier()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "oidcState", state)
+	app.sessionManager.Put(r.Context(), "oidcNonce", nonce)
+	app.sessionManager.Put(r.Context(), "oidcVerifier", verifier)
+
+	if reauth {
+		app.sessionManager.Put(r.Context(), "oidcReauth", true)
+		http.Redirect(w, r, app.oidcProvider.ReauthCodeURL(state, nonce, verifier), http.StatusSeeOther)
+		return
+	}
+
+	app.sessionManager.Remove(r.Context(), "oidcReauth")
+	http.Redirect(w, r, app.oidcProvider.AuthCodeURL(state, nonce, verifier), http.StatusSeeOther)
+}
+
+func (app *application) userLoginOIDCCallback(w http.ResponseWriter, r *http.Request) {
+	if app.oidcProvider == nil {
+		app.notFound(w)
+		return
+	}
+
+	// Each login attempt's values can only be used once.
+	// 每次登录的这些值只能使用一次
+	state := app.sessionManager.PopString(r.Context(), "oidcState")
+	nonce := app.sessionManager.PopString(r.Context(), "oidcNonce")
+	verifier := app.sessionManager.PopString(r.Context(), "oidcVerifier")
+	reauth := app.sessionManager.PopBool(r.Context(), "oidcReauth")
+
+	q := r.URL.Query()
+	if state == "" || subtle.ConstantTimeCompare([]byte(q.Get("state")), []byte(state)) != 1 {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	// The user may have cancelled, or the provider refused to log them in.
+	// 用户可能取消了登录，或者身份提供方拒绝了登录
+	if q.Get("error") != "" || q.Get("code") == "" {
+		if reauth {
+			app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("You didn't confirm who you are with %s.", app.oidcName))
+			http.Redirect(w, r, "/account/delete", http.StatusSeeOther)
+			return
+		}
+		app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("You weren't logged in with %s.", app.oidcName))
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	rawIDToken, err := app.oidcProvider.Exchange(r.Context(), q.Get("code"), verifier)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	claims, err := app.oidcProvider.Verify(r.Context(), rawIDToken, nonce)
+	if err != nil {
+		if errors.Is(err, oidc.ErrInvalidToken) {
+			app.errorLog.Print(err)
+			app.clientError(w, http.StatusBadRequest)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	if reauth {
+		app.oidcReauthenticated(w, r, claims)
+		return
+	}
+
+	id, message, err := app.oidcUserID(r, claims)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if message != "" {
+		app.sessionManager.Put(r.Context(), "flash", message)
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	user, err := app.users.Get(r.Context(), id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	ip := clientIP(r)
+
+	if user.Disabled {
+		err = app.loginAttempts.Record(user.Email, ip, models.LoginDisabled)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		app.audit(r, audit.LoginFailure, 0, user.Email, "account disabled")
+		app.sessionManager.Put(r.Context(), "flash", "This account has been disabled.")
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	err = app.sessionManager.RenewToken(r.Context())
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// Two-factor authentication still applies, just as it does after a
+	// password, and the login is only recorded as a success once it's done.
+	// 和密码登录一样，开启了两步验证的用户仍然需要输入验证码，验证通过之后才记录为登录成功
+	if user.TOTPEnabled() {
+		app.sessionManager.Put(r.Context(), "pendingTwoFactorUserID", id)
+		app.sessionManager.Put(r.Context(), "pendingRememberMe", false)
+		app.sessionManager.Put(r.Context(), "pendingLoginMethod", "sso")
+		http.Redirect(w, r, "/user/login/2fa", http.StatusSeeOther)
+		return
+	}
+
+	err = app.loginAttempts.Record(user.Email, ip, models.LoginSuccess)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	err = app.logIn(r, id, false)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.audit(r, audit.LoginSuccess, id, user.Email, "sso")
+
+	http.Redirect(w, r, "/snippet/create", http.StatusSeeOther)
+}
+
+// oidcReauthenticated finishes confirming who a logged in user is with
+// single sign-on. The external account has to be linked to them, and the
+// provider has to say that they've only just logged in there, so that a
+// session left open at the provider can't stand in for it.
+// 完成通过单点登录确认已登录用户身份的流程。外部账号必须关联到这个用户，并且身份提供方必须说明用户刚刚在那里登录过，
+// 这样在身份提供方那里没有退出的会话不能代替用户本人确认
+func (app *application) oidcReauthenticated(w http.ResponseWriter, r *http.Request, claims *oidc.Claims) {
+	user := app.authenticatedUser(r)
+	if user == nil {
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	id, err := app.identities.GetUserID(claims.Issuer, claims.Subject)
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		app.serverError(w, err)
+		return
+	}
+
+	switch {
+	case id != user.ID:
+		app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("That %s account isn't linked to yours.", app.oidcName))
+	case claims.AuthTime.IsZero() || time.Since(claims.AuthTime) > reauthWindow:
+		app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("%s didn't ask you to log in again, so please confirm with your password instead.", app.oidcName))
+	default:
+		app.sessionManager.Put(r.Context(), "reauthenticatedUserID", user.ID)
+		app.sessionManager.Put(r.Context(), "reauthenticatedAt", time.Now())
+	}
+
+	http.Redirect(w, r, "/account/delete", http.StatusSeeOther)
+}
+
+// oidcUserID finds the user for an external account. An account we've seen
+// before is already linked. Otherwise it's linked to the user with the same
+// email address, or a new user is created, but only if the provider vouches
+// for the email address. If the user can't be logged in, message says why.
+// 查找外部账号对应的用户。之前见过的账号已经关联好了；否则关联到邮箱相同的用户，或者创建一个新用户，
+// 但前提是身份提供方确认过这个邮箱。如果不能登录，message 会说明原因
+func (app *application) oidcUserID(r *http.Request, claims *oidc.Claims) (id int, message string, err error) {
+	id, err = app.identities.GetUserID(claims.Issuer, claims.Subject)
+	if err == nil {
+		return id, "", nil
+	}
+	if !errors.Is(err, models.ErrNoRecord) {
+		return 0, "", err
+	}
+
+	if !claims.EmailVerified || !validator.Matches(claims.Email, validator.EmailRX) {
+		return 0, fmt.Sprintf("%s didn't give us a verified email address, so we couldn't log you in.", app.oidcName), nil
+	}
+
+	user, err := app.users.GetByEmail(r.Context(), claims.Email)
+	switch {
+	case err == nil:
+		// Someone could have signed up with this address without owning
+		// it. Don't hand them the real owner's SSO login until the address
+		// has been verified here too.
+		// 有人可能在并不拥有这个邮箱的情况下用它注册了账号。在这里也验证过邮箱之前，不要把它和真正主人的单点登录关联起来
+		if !user.EmailVerified {
+			return 0, "An account with this email address exists but hasn't been verified. Please reset its password to verify it, then try again.", nil
+		}
+		id = user.ID
+
+	case errors.Is(err, models.ErrNoRecord):
+		// Single sign-on can't be used to get round closed or invite-only
+		// registration.
+		// 不能通过单点登录绕过关闭注册或仅邀请注册
+		if app.registration != registrationOpen {
+			return 0, "There's no account for your email address, and new signups need an invitation.", nil
+		}
+		id, err = app.createOIDCUser(r.Context(), claims)
+		if err != nil {
+			return 0, "", err
+		}
+		app.audit(r, audit.Signup, id, claims.Email, "sso")
+
+	default:
+		return 0, "", err
+	}
+
+	err = app.identities.Insert(id, claims.Issuer, claims.Subject)
+	if err != nil {
+		return 0, "", err
+	}
+
+	return id, "", nil
+}
+
+// createOIDCUser creates a verified account for someone logging in with
+// single sign-on for the first time. They get a random password which nobody
+// knows; they can set a real one with the forgotten password form.
+// 为第一次使用单点登录的用户创建已验证的账号。密码是随机的，没有人知道，用户可以通过忘记密码来设置真正的密码
+func (app *application) createOIDCUser(ctx context.Context, claims *oidc.Claims) (int, error) {
+	name := strings.TrimSpace(claims.Name)
+	if name == "" {
+		name, _, _ = strings.Cut(claims.Email, "@")
+	}
+	if utf8.RuneCountInString(name) > 100 {
+		name = string([]rune(name)[:100])
+	}
+
+	randomPassword, err := oidc.NewVerifier()
+	if err != nil {
+		return 0, err
+	}
+
+	// They can choose a handle later, from their account page.
+	// 用户之后可以在账号页面选择 handle
+	id, err := app.users.Insert(ctx, name, "", claims.Email, randomPassword)
+	if err != nil {
+		return 0, err
+	}
+
+	err = app.users.SetEmailVerified(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// invitationsLeft returns how many more invitations the user can create right
+// now. Administrators aren't limited, which is reported as -1.
+// 返回用户当前还能创建多少个邀请，管理员不受限制，返回 -1
+func (app *application) invitationsLeft(user *models.User) (int, error) {
+	if user.IsAdmin() {
+		return -1, nil
+	}
+
+	n, err := app.invitations.Outstanding(user.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	if n >= app.invitationsPerUser {
+		return 0, nil
+	}
+	return app.invitationsPerUser - n, nil
+}
+
+// renderInvitations shows the user's invitations, along with a new signup link
+// if one has just been created.
+// 显示用户的邀请列表，如果刚刚创建了新的邀请，同时显示注册链接
+func (app *application) renderInvitations(w http.ResponseWriter, r *http.Request, invitationURL string) {
+	user := app.authenticatedUser(r)
+
+	invitations, err := app.invitations.ListForUser(user.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	left, err := app.invitationsLeft(user)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Invitations = invitations
+	data.InvitationURL = invitationURL
+	data.InvitationsLeft = left
+	app.render(w, http.StatusOK, "invitations.tmpl", data)
+}
+
+func (app *application) accountInvitations(w http.ResponseWriter, r *http.Request) {
+	if app.registration != registrationInvite {
+		app.notFound(w)
+		return
+	}
+
+	app.renderInvitations(w, r, "")
+}
+
+func (app *application) accountInvitationsPost(w http.ResponseWriter, r *http.Request) {
+	if app.registration != registrationInvite {
+		app.notFound(w)
+		return
+	}
+
+	user := app.authenticatedUser(r)
+
+	left, err := app.invitationsLeft(user)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if left == 0 {
+		app.sessionManager.Put(r.Context(), "flash", "You can't send any more invitations until some of yours have been used or have expired.")
+		http.Redirect(w, r, "/account/invitations", http.StatusSeeOther)
+		return
+	}
+
+	code, err := app.invitations.New(user.ID, invitationTTL)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.audit(r, audit.InvitationCreate, user.ID, user.Email, "")
+
+	// Like recovery codes, the link is rendered directly because this is
+	// the only time the plaintext code is available.
+	// 和恢复码一样直接渲染链接，因为只有这一次能拿到明文邀请码
+	app.renderInvitations(w, r, fmt.Sprintf("%s/user/signup?invite=%s", app.baseURL, url.QueryEscape(code)))
+}
+
+// profileSnippetLimit is how many snippets a profile page shows.
+// 个人主页显示的 snippet 数量
+const profileSnippetLimit = 20
+
+func (app *application) userProfile(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	handle := validator.NormalizeHandle(params.ByName("handle"))
+
+	// Send people to the canonical, lower case URL.
+	// 重定向到规范的小写 URL
+	if handle != params.ByName("handle") {
+		http.Redirect(w, r, "/u/"+url.PathEscape(handle), http.StatusMovedPermanently)
+		return
+	}
+
+	user, err := app.users.GetByHandle(r.Context(), handle)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+	if user.Disabled {
+		app.notFound(w)
+		return
+	}
+
+	snippets, err := app.snippets.LatestForUser(r.Context(), user.ID, profileSnippetLimit)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Profile = user
+	data.Snippets = snippets
+	app.render(w, http.StatusOK, "profile.tmpl", data)
+}
+
+type accountHandleForm struct {
+	Handle              string `form:"handle"`
+	validator.Validator `form:"-"`
+}
+
+func (app *application) accountHandlePost(w http.ResponseWriter, r *http.Request) {
+	var form accountHandleForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	user := app.authenticatedUser(r)
+
+	form.Handle = validator.NormalizeHandle(form.Handle)
+	app.checkHandle(&form.Validator, "handle", form.Handle)
+
+	if form.Valid() && form.Handle != user.Handle {
+		err = app.users.SetHandle(r.Context(), user.ID, form.Handle)
+		app.snippetsChanged()
+		if err != nil {
+			if !errors.Is(err, models.ErrDuplicateHandle) {
+				app.serverError(w, err)
+				return
+			}
+			form.AddFieldError("handle", "Handle is already taken")
+		} else {
+			app.audit(r, audit.AccountHandleChange, user.ID, user.Email, fmt.Sprintf("%q to %q", user.Handle, form.Handle))
+		}
+	}
+
+	if !form.Valid() {
+		app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("Your handle wasn't changed: %s.", strings.ToLower(form.FieldErrors["handle"])))
+		http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Your handle has been updated.")
+	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+}
@@ -7,11 +7,174 @@ import (
 	"github.com/go-playground/form/v4"
 	"net/http"
 	"runtime/debug"
+	"snippetbox.ab.net/internal/audit"
+	"snippetbox.ab.net/internal/database"
+	"snippetbox.ab.net/internal/models"
+	"strings"
 	"time"
 )
 
+// authenticatedUser returns the user loaded by the authenticate middleware,
+// or nil if the request isn't from a logged in user.
+// 返回 authenticate 中间件加载的用户，未登录时返回 nil
+func (app *application) authenticatedUser(r *http.Request) *models.User {
+	user, ok := r.Context().Value(authenticatedUserContextKey).(*models.User)
+	if !ok {
+		return nil
+	}
+	return user
+}
+
 func (app *application) isAuthenticated(r *http.Request) bool {
-	return app.sessionManager.Exists(r.Context(), "authenticatedUserID")
+	return app.authenticatedUser(r) != nil
+}
+
+// logIn marks the session as belonging to the user and adds it to the
+// user's list of active sessions. The caller must already have renewed the
+// session token. A "remember me" login gets a persistent cookie, any other
+// login a browser-session cookie which expires after app.loginLifetime.
+// 把当前会话标记为属于该用户，并加入用户的活跃会话列表，调用前必须已经更新过 session token。
+// "记住我"登录使用持久化 cookie，其他登录使用浏览器会话级别的 cookie，并在 app.loginLifetime 之后过期
+func (app *application) logIn(r *http.Request, userID int, rememberMe bool) error {
+	app.sessionManager.Put(r.Context(), "authenticatedUserID", userID)
+
+	if rememberMe {
+		app.sessionManager.RememberMe(r.Context(), true)
+		app.sessionManager.Remove(r.Context(), "loginExpires")
+	} else {
+		app.sessionManager.RememberMe(r.Context(), false)
+		app.sessionManager.Put(r.Context(), "loginExpires", time.Now().Add(app.loginLifetime))
+	}
+
+	ua := r.UserAgent()
+	if len(ua) > 512 {
+		ua = ua[:512]
+	}
+
+	return app.sessions.Insert(app.sessionManager.Token(r.Context()), userID, clientIP(r), ua, describeDevice(ua))
+}
+
+// revokeSessions logs the user out everywhere except the session with the
+// token keep, by deleting the session data from the store as well as the
+// index.
+// 注销用户除 keep 以外的所有会话，同时删除 session store 中的数据和索引
+func (app *application) revokeSessions(userID int, keep string) error {
+	tokens, err := app.sessions.DeleteAllForUser(userID, keep)
+	if err != nil {
+		return err
+	}
+
+	for _, token := range tokens {
+		err = app.sessionManager.Store.Delete(token)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// pruneSessions deletes expired sessions from the index every interval
+// until stop is closed. The session stores clean up the session data
+// themselves, but don't know about the index.
+// 每隔 interval 从索引中删除过期的会话，直到 stop 被关闭。session store 会自己清理 session 数据，
+// 但是并不知道索引
+func (app *application) pruneSessions(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			err := app.sessions.DeleteExpired()
+			if err != nil {
+				app.errorLog.Print(err)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// snippetsChanged empties the snippet cache, if there is one, after a
+// change to snippets or their authors which didn't go through it, such as
+// deleting a user or changing their handle.
+// 在没有经过 snippet 缓存修改 snippet 或作者之后（例如删除用户或修改 handle）清空缓存（如果有的话）
+func (app *application) snippetsChanged() {
+	if c, ok := app.snippets.(*models.SnippetCache); ok {
+		c.Purge()
+	}
+}
+
+// wroteSnippets makes the user's reads go to the primary database for a
+// while, so that they see a change they've just made even if the replica
+// hasn't caught up with it yet.
+// 让用户在一段时间内从主库读取，这样即使只读副本还没有同步，他们也能看到刚做的修改
+func (app *application) wroteSnippets(r *http.Request) {
+	if app.replica == nil {
+		return
+	}
+	app.sessionManager.Put(r.Context(), "readPrimaryUntil", time.Now().Add(app.replicaStickiness))
+}
+
+// audit records an event in the audit log with the client's IP address and
+// user agent. By the time it's called the action has already happened, so a
+// failure to record it is logged rather than failing the request.
+// 在审计日志中记录一个事件，包括客户端的 IP 地址和 user agent。调用时操作已经完成，
+// 所以记录失败只写日志，不让请求失败
+func (app *application) audit(r *http.Request, action string, actorID int, subject, detail string) {
+	err := app.auditLog.Record(audit.Event{
+		Action:    action,
+		ActorID:   actorID,
+		Subject:   subject,
+		IP:        clientIP(r),
+		UserAgent: r.UserAgent(),
+		Detail:    detail,
+	})
+	if err != nil {
+		app.errorLog.Output(2, fmt.Sprintf("audit %s: %v", action, err))
+	}
+}
+
+// describeDevice turns a User-Agent header into a short description like
+// "Firefox on Linux". It only needs to be good enough for people to recognise
+// their own devices.
+// 把 User-Agent 转换成 "Firefox on Linux" 这样的简短描述，只要用户能认出自己的设备就行
+func describeDevice(ua string) string {
+	browser := "Unknown browser"
+	switch {
+	case strings.Contains(ua, "Edg/"):
+		browser = "Edge"
+	case strings.Contains(ua, "OPR/"):
+		browser = "Opera"
+	case strings.Contains(ua, "Firefox/"):
+		browser = "Firefox"
+	case strings.Contains(ua, "Chrome/"):
+		browser = "Chrome"
+	case strings.Contains(ua, "Safari/"):
+		browser = "Safari"
+	case strings.Contains(ua, "curl/"):
+		browser = "curl"
+	}
+
+	os := ""
+	switch {
+	case strings.Contains(ua, "iPhone"), strings.Contains(ua, "iPad"):
+		os = "iOS"
+	case strings.Contains(ua, "Android"):
+		os = "Android"
+	case strings.Contains(ua, "Windows"):
+		os = "Windows"
+	case strings.Contains(ua, "Mac OS X"):
+		os = "macOS"
+	case strings.Contains(ua, "Linux"):
+		os = "Linux"
+	}
+
+	if os == "" {
+		return browser
+	}
+	return browser + " on " + os
 }
 
 // Create an newTemplateData() helper, which returns a pointer to a templateData
@@ -24,10 +187,34 @@ func (app *application) newTemplateData(r *http.Request) *templateData {
 		// Add the flash message to the template data, if one exists.
 		Flash:           app.sessionManager.PopString(r.Context(), "flash"),
 		IsAuthenticated: app.isAuthenticated(r),
+		User:            app.authenticatedUser(r),
+		OIDCName:        app.oidcLoginName(),
+		Registration:    app.registration,
 	}
 }
 
+// oidcLoginName returns the name to show on the single sign-on button, or ""
+// if single sign-on isn't configured.
+// 返回单点登录按钮上显示的名称，未配置单点登录时返回空字符串
+func (app *application) oidcLoginName() string {
+	if app.oidcProvider == nil {
+		return ""
+	}
+	return app.oidcName
+}
+
 func (app *application) serverError(w http.ResponseWriter, err error) {
+	// A query which ran out of time means that the database is overloaded
+	// or unreachable rather than that something is broken, so ask the
+	// client to try again shortly.
+	// 查询超时说明数据库过载或无法访问，而不是程序出了问题，所以让客户端稍后重试
+	if database.IsTimeout(err) {
+		app.errorLog.Output(2, err.Error())
+		w.Header().Set("Retry-After", "5")
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+
 	trace := fmt.Sprintf("%s\n%s", err.Error(), debug.Stack())
 	err = app.errorLog.Output(2, trace)
 	if err != nil {
@@ -36,6 +223,26 @@ func (app *application) serverError(w http.ResponseWriter, err error) {
 	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 }
 
+// background runs fn in a new goroutine, recovering from any panic so that
+// it can't bring down the whole server. The WaitGroup lets us wait for
+// background work to finish.
+// 在后台 goroutine 中运行 fn，并捕获 panic
+func (app *application) background(fn func()) {
+	app.wg.Add(1)
+
+	go func() {
+		defer app.wg.Done()
+
+		defer func() {
+			if err := recover(); err != nil {
+				app.errorLog.Output(2, fmt.Sprintf("%s\n%s", err, debug.Stack()))
+			}
+		}()
+
+		fn()
+	}()
+}
+
 func (app *application) clientError(w http.ResponseWriter, status int) {
 	http.Error(w, http.StatusText(status), status)
 }
@@ -1,10 +1,19 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"snippetbox.ab.net/internal/models"
+	"time"
 )
 
+// sessionTouchInterval is how often the last seen time of a session is
+// updated.
+// 会话最后活跃时间的更新间隔
+const sessionTouchInterval = time.Minute
+
 func secureHeaders(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Note: This is split across multiple lines for readability. You don't
@@ -52,6 +61,14 @@ func (app *application) recoverPanic(next http.Handler) http.Handler {
 
 func (app *application) requireAuthentication(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A user who has entered their password but not yet their two-factor
+		// code isn't logged in. Send them back to finish.
+		// 已经输入密码但还没有输入两步验证码的用户还不算登录，让他们先完成验证
+		if !app.isAuthenticated(r) && app.sessionManager.Exists(r.Context(), "pendingTwoFactorUserID") {
+			http.Redirect(w, r, "/user/login/2fa", http.StatusSeeOther)
+			return
+		}
+
 		// 用户如果没有登录，重定向到登录页
 		if !app.isAuthenticated(r) {
 			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
@@ -64,3 +81,122 @@ func (app *application) requireAuthentication(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// readYourWrites sends the request's snippet reads to the primary database
+// if the user has written a snippet recently; see wroteSnippets.
+// 如果用户最近写过 snippet，让请求中的 snippet 读取使用主库，参见 wroteSnippets
+func (app *application) readYourWrites(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		until := app.sessionManager.GetTime(r.Context(), "readPrimaryUntil")
+		if !until.IsZero() {
+			if time.Now().Before(until) {
+				r = r.WithContext(models.WithPrimary(r.Context()))
+			} else {
+				app.sessionManager.Remove(r.Context(), "readPrimaryUntil")
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authenticate loads the logged in user (if any) from the database and stores
+// it in the request context. If the user has been deleted since they logged
+// in, the stale ID is removed from the session.
+// 从数据库中加载当前登录的用户并存入请求上下文，如果用户已被删除则清理 session
+func (app *application) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+		if id == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		user, err := app.users.Get(r.Context(), id)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				app.sessionManager.Remove(r.Context(), "authenticatedUserID")
+				next.ServeHTTP(w, r)
+			} else {
+				app.serverError(w, err)
+			}
+			return
+		}
+
+		// Make sure the session hasn't been logged out from another device in
+		// the meantime, and that the account hasn't been disabled. If either
+		// has happened, treat the request as anonymous.
+		// 确认这个会话没有在其他设备上被注销，并且账号没有被禁用，否则视为未登录
+		token := app.sessionManager.Token(r.Context())
+		sess, err := app.sessions.Get(token)
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
+			app.serverError(w, err)
+			return
+		}
+		if sess == nil || sess.UserID != id || user.Disabled {
+			app.sessionManager.Remove(r.Context(), "authenticatedUserID")
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Ordinary (not "remember me") logins only last for a fixed time.
+		// 普通登录（非"记住我"）只在固定的时间内有效
+		expires := app.sessionManager.GetTime(r.Context(), "loginExpires")
+		if !expires.IsZero() && time.Now().After(expires) {
+			err = app.sessions.Delete(token)
+			if err != nil {
+				app.serverError(w, err)
+				return
+			}
+			app.sessionManager.Remove(r.Context(), "authenticatedUserID")
+			app.sessionManager.Remove(r.Context(), "loginExpires")
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Only write last seen times now and then, rather than on every request.
+		// 只是偶尔更新最后活跃时间，而不是每个请求都写数据库
+		if time.Since(sess.LastSeen) > sessionTouchInterval {
+			err = app.sessions.Touch(token, clientIP(r))
+			if err != nil {
+				app.serverError(w, err)
+				return
+			}
+		}
+
+		ctx := context.WithValue(r.Context(), authenticatedUserContextKey, user)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// requireVerifiedEmail must come after requireAuthentication in a chain. It
+// sends users who haven't verified their email address yet to a page
+// explaining how to do so.
+// 必须放在 requireAuthentication 之后，邮箱未验证的用户会被重定向到验证说明页面
+func (app *application) requireVerifiedEmail(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.authenticatedUser(r).EmailVerified {
+			http.Redirect(w, r, "/user/verify", http.StatusSeeOther)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireRole returns a middleware which only lets users with the given role
+// through. Like requireVerifiedEmail it must come after requireAuthentication
+// in a chain.
+// 返回一个只允许特定角色的用户通过的中间件，和 requireVerifiedEmail 一样必须放在 requireAuthentication 之后
+func (app *application) requireRole(role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if app.authenticatedUser(r).Role != role {
+				app.clientError(w, http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
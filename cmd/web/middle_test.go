@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"github.com/alexedwards/scs/v2"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"snippetbox.ab.net/internal/database"
+	"snippetbox.ab.net/internal/migrate"
+	"snippetbox.ab.net/internal/models"
+	"testing"
+	"time"
+)
+
+// newTestDB returns a new in-memory SQLite database with the schema
+// migrated, which is closed when the test finishes.
+func newTestDB(t *testing.T) *database.DB {
+	t.Helper()
+
+	db, err := database.Open("sqlite::memory:?_foreign_keys=off")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrator, err := migrate.New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = migrator.Up(context.Background(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func TestReadYourWrites(t *testing.T) {
+	// The replica never catches up, so the snippet can only be read from the
+	// primary.
+	db, replica := newTestDB(t), newTestDB(t)
+	snippets := &models.SnippetModel{DB: db, Replica: replica}
+	id, err := snippets.Insert(context.Background(), 0, "Title", "Content", 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// main registers this for the session data.
+	gob.Register(time.Time{})
+
+	app := &application{
+		snippets:          snippets,
+		replica:           replica,
+		replicaStickiness: 50 * time.Millisecond,
+		sessionManager:    scs.New(),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/write", func(w http.ResponseWriter, r *http.Request) {
+		app.wroteSnippets(r)
+	})
+	mux.HandleFunc("/read", func(w http.ResponseWriter, r *http.Request) {
+		_, err := app.snippets.Get(r.Context(), id)
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		}
+	})
+
+	srv := httptest.NewServer(app.sessionManager.LoadAndSave(app.readYourWrites(mux)))
+	t.Cleanup(srv.Close)
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Jar: jar}
+
+	steps := []struct {
+		name  string
+		path  string
+		sleep time.Duration // before the request
+		want  int
+	}{
+		{name: "Before writing", path: "/read", want: http.StatusNotFound},
+		{name: "Write", path: "/write", want: http.StatusOK},
+		{name: "Just after writing", path: "/read", want: http.StatusOK},
+		{name: "After the stickiness", path: "/read", sleep: 100 * time.Millisecond, want: http.StatusNotFound},
+	}
+
+	for _, step := range steps {
+		time.Sleep(step.sleep)
+
+		res, err := client.Get(srv.URL + step.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+
+		if res.StatusCode != step.want {
+			t.Errorf("%s: got status %d; want %d", step.name, res.StatusCode, step.want)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"snippetbox.ab.net/internal/migrate"
+	"strconv"
+	"text/tabwriter"
+)
+
+// runMigration carries out a -migrate command:
+//
+//	up [VERSION]    apply pending migrations, up to VERSION if given
+//	down [STEPS]    roll back the newest STEPS migrations (default 1), but
+//	                never PostgreSQL's or SQLite's baseline, 0015_create_schema
+//	status          list migrations and whether they've been applied
+//	force VERSION   record the schema as being at VERSION without running anything
+//
+// 执行 -migrate 命令：up 应用待执行的迁移，down 回滚最新的迁移（但不会回滚 PostgreSQL 和 SQLite 的基线迁移
+// 0015_create_schema），status 显示迁移状态，
+// force 不执行任何脚本，直接把数据库结构记录为指定版本
+func runMigration(m *migrate.Migrator, command string, args []string, out io.Writer, infoLog *log.Logger) error {
+	ctx := context.Background()
+
+	arg := func(def int) (int, error) {
+		if len(args) == 0 {
+			return def, nil
+		}
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 1 {
+			return 0, fmt.Errorf("-migrate %s: %q isn't a positive number", command, args[0])
+		}
+		return n, nil
+	}
+
+	switch command {
+	case "up":
+		target, err := arg(0)
+		if err != nil {
+			return err
+		}
+
+		done, err := m.Up(ctx, target)
+		for _, mig := range done {
+			infoLog.Printf("Applied %04d_%s", mig.Version, mig.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(done) == 0 {
+			infoLog.Print("The schema is already up to date")
+		}
+
+	case "down":
+		steps, err := arg(1)
+		if err != nil {
+			return err
+		}
+
+		done, err := m.Down(ctx, steps)
+		for _, mig := range done {
+			infoLog.Printf("Rolled back %04d_%s", mig.Version, mig.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(done) == 0 {
+			infoLog.Print("There's nothing to roll back")
+		}
+
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		tw := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED")
+		for _, s := range statuses {
+			applied := "pending"
+			if s.IsApplied() {
+				applied = s.Applied.Format("2006-01-02 15:04:05")
+			}
+			if s.Dirty {
+				applied += " (dirty)"
+			}
+			fmt.Fprintf(tw, "%04d\t%s\t%s\n", s.Version, s.Name, applied)
+		}
+		return tw.Flush()
+
+	case "force":
+		if len(args) == 0 {
+			return fmt.Errorf("-migrate force needs a version")
+		}
+		version, err := arg(0)
+		if err != nil {
+			return err
+		}
+
+		err = m.Force(ctx, version)
+		if err != nil {
+			return err
+		}
+		infoLog.Printf("Recorded the schema as being at version %04d", version)
+
+	default:
+		return fmt.Errorf("unknown -migrate command %q (up|down|status|force)", command)
+	}
+
+	return nil
+}
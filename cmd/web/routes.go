@@ -5,6 +5,7 @@ import (
 	"github.com/justinas/alice"
 
 	"net/http"
+	"snippetbox.ab.net/internal/models"
 )
 
 // Update the signature for the routes() method so that it returns a
@@ -25,9 +26,13 @@ func (app *application) routes() http.Handler {
 	fileServer := http.FileServer(http.Dir("./ui/static/"))
 	router.Handler(http.MethodGet, "/static/*filepath", http.StripPrefix("/static", fileServer))
 
+	// Avatars don't need the session, and leaving it out keeps them cacheable.
+	// 头像不需要 session，不使用 session 中间件可以让它们被缓存
+	router.HandlerFunc(http.MethodGet, "/avatar/:id/:version/:size", app.avatarImage)
+
 	// 不需要登录验证的路由使用 dynamic 中间件链
 	// Unprotected application routes using the "dynamic" middleware chain.
-	dynamic := alice.New(app.sessionManager.LoadAndSave)
+	dynamic := alice.New(app.sessionManager.LoadAndSave, app.readYourWrites, app.authenticate)
 
 	// Update these routes to use the new dynamic middleware chain followed by
 	// the appropriate handler function. Note that because the alice ThenFunc()
@@ -36,17 +41,64 @@ func (app *application) routes() http.Handler {
 	// 更新路由来使用新的 dynamic 中间件，因为 ThenFunc() 方法返回一个 http.Handler，我们需要使用 Handler 替代 HandlerFunc
 	router.Handler(http.MethodGet, "/", dynamic.ThenFunc(app.home))
 	router.Handler(http.MethodGet, "/snippet/view/:id", dynamic.ThenFunc(app.snippetView))
+	router.Handler(http.MethodGet, "/u/:handle", dynamic.ThenFunc(app.userProfile))
 	router.Handler(http.MethodGet, "/user/signup", dynamic.ThenFunc(app.userSignup))
 	router.Handler(http.MethodPost, "/user/signup", dynamic.ThenFunc(app.userSignupPost))
 	router.Handler(http.MethodGet, "/user/login", dynamic.ThenFunc(app.userLogin))
 	router.Handler(http.MethodPost, "/user/login", dynamic.ThenFunc(app.userLoginPost))
+	router.Handler(http.MethodGet, "/user/login/2fa", dynamic.ThenFunc(app.userLoginTwoFactor))
+	router.Handler(http.MethodPost, "/user/login/2fa", dynamic.ThenFunc(app.userLoginTwoFactorPost))
+	router.Handler(http.MethodGet, "/user/login/oidc", dynamic.ThenFunc(app.userLoginOIDC))
+	router.Handler(http.MethodGet, "/user/login/oidc/callback", dynamic.ThenFunc(app.userLoginOIDCCallback))
+	router.Handler(http.MethodGet, "/user/password/forgot", dynamic.ThenFunc(app.userForgotPassword))
+	router.Handler(http.MethodPost, "/user/password/forgot", dynamic.ThenFunc(app.userForgotPasswordPost))
+	router.Handler(http.MethodGet, "/user/password/reset/:token", dynamic.ThenFunc(app.userResetPassword))
+	router.Handler(http.MethodPost, "/user/password/reset/:token", dynamic.ThenFunc(app.userResetPasswordPost))
+	router.Handler(http.MethodGet, "/user/verify/:token", dynamic.ThenFunc(app.userVerifyEmail))
 	// 需要登录验证的路由使用 protected 中间件调用链
 	// Protected (authenticated-only) application routes, using a new "protected"
 	// middleware chain which includes the requireAuthentication middleware.
 	protected := dynamic.Append(app.requireAuthentication)
-	router.Handler(http.MethodGet, "/snippet/create", protected.ThenFunc(app.snippetCreate))
-	router.Handler(http.MethodPost, "/snippet/create", protected.ThenFunc(app.snippetCreatePost))
+	router.Handler(http.MethodGet, "/user/verify", protected.ThenFunc(app.userVerify))
+	router.Handler(http.MethodPost, "/user/verify/resend", protected.ThenFunc(app.userVerifyResendPost))
 	router.Handler(http.MethodPost, "/user/logout", protected.ThenFunc(app.userLogoutPost))
+	router.Handler(http.MethodGet, "/account/view", protected.ThenFunc(app.accountView))
+	router.Handler(http.MethodPost, "/account/handle", protected.ThenFunc(app.accountHandlePost))
+	router.Handler(http.MethodPost, "/account/avatar", protected.ThenFunc(app.accountAvatarPost))
+	router.Handler(http.MethodPost, "/account/avatar/delete", protected.ThenFunc(app.accountAvatarDeletePost))
+	router.Handler(http.MethodGet, "/account/2fa/enable", protected.ThenFunc(app.accountTOTPEnable))
+	router.Handler(http.MethodPost, "/account/2fa/enable", protected.ThenFunc(app.accountTOTPEnablePost))
+	router.Handler(http.MethodGet, "/account/2fa/qr.png", protected.ThenFunc(app.accountTOTPQRCode))
+	router.Handler(http.MethodPost, "/account/2fa/disable", protected.ThenFunc(app.accountTOTPDisablePost))
+	router.Handler(http.MethodPost, "/account/2fa/recovery-codes", protected.ThenFunc(app.accountRecoveryCodesPost))
+	router.Handler(http.MethodPost, "/account/sessions/revoke/:id", protected.ThenFunc(app.accountSessionDeletePost))
+	router.Handler(http.MethodPost, "/account/sessions/revoke-others", protected.ThenFunc(app.accountSessionsRevokeOthersPost))
+	router.Handler(http.MethodGet, "/account/export", protected.ThenFunc(app.accountExport))
+	router.Handler(http.MethodGet, "/account/delete", protected.ThenFunc(app.accountDelete))
+	router.Handler(http.MethodPost, "/account/delete", protected.ThenFunc(app.accountDeletePost))
+	router.Handler(http.MethodGet, "/account/delete/sso", protected.ThenFunc(app.accountDeleteSSO))
+
+	// Only users with a verified email address can publish snippets.
+	// 只有验证过邮箱的用户才能发布 snippet
+	verified := protected.Append(app.requireVerifiedEmail)
+	router.Handler(http.MethodGet, "/snippet/create", verified.ThenFunc(app.snippetCreate))
+	router.Handler(http.MethodPost, "/snippet/create", verified.ThenFunc(app.snippetCreatePost))
+	router.Handler(http.MethodGet, "/account/invitations", verified.ThenFunc(app.accountInvitations))
+	router.Handler(http.MethodPost, "/account/invitations", verified.ThenFunc(app.accountInvitationsPost))
+
+	// 管理员专用的路由
+	// Routes for administrators only.
+	admin := protected.Append(app.requireRole(models.RoleAdmin))
+	router.Handler(http.MethodGet, "/admin", admin.ThenFunc(app.adminHome))
+	router.Handler(http.MethodGet, "/admin/users", admin.ThenFunc(app.adminUsers))
+	router.Handler(http.MethodPost, "/admin/users/:id/disable", admin.ThenFunc(app.adminUserDisablePost))
+	router.Handler(http.MethodPost, "/admin/users/:id/enable", admin.ThenFunc(app.adminUserEnablePost))
+	router.Handler(http.MethodGet, "/admin/snippets", admin.ThenFunc(app.adminSnippets))
+	router.Handler(http.MethodPost, "/admin/snippets/:id/delete", admin.ThenFunc(app.adminSnippetDeletePost))
+	router.Handler(http.MethodGet, "/admin/logins", admin.ThenFunc(app.adminLogins))
+	router.Handler(http.MethodGet, "/admin/audit", admin.ThenFunc(app.adminAudit))
+	router.Handler(http.MethodGet, "/admin/audit/export", admin.ThenFunc(app.adminAuditExport))
+	router.Handler(http.MethodGet, "/admin/diagnostics", admin.ThenFunc(app.adminDiagnostics))
 
 	standard := alice.New(app.recoverPanic, app.logRequest, secureHeaders)
 
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"snippetbox.ab.net/internal/listener"
+	"syscall"
+	"time"
+)
+
+// serve runs srv until it fails or the process gets SIGINT or SIGTERM. On a
+// signal it stops accepting connections and waits up to drainTimeout for
+// requests in flight, and then background work such as sending emails, to
+// finish. It returns nil if everything finished in time.
+//
+// The listening socket is inherited if one was passed down, and on
+// listener.HandoffSignal (SIGUSR2) it's handed over to a new process, which
+// sends this one SIGTERM once it's ready.
+//
+// 运行 srv，直到出错或者进程收到 SIGINT 或 SIGTERM。收到信号后停止接受新连接，
+// 最多等待 drainTimeout，让正在处理的请求以及发送邮件等后台任务完成。全部按时完成时返回 nil。
+//
+// 如果有传下来的监听 socket 就继承它。收到 listener.HandoffSignal（SIGUSR2）时把它移交给新进程，
+// 新进程准备好之后会向这个进程发送 SIGTERM
+func (app *application) serve(srv *http.Server, certFile, keyFile string, drainTimeout time.Duration) error {
+	// Load the certificate before taking over a listener, so that a new
+	// process with a bad one fails without the old process shutting down.
+	// 在接管监听器之前加载证书，这样证书有问题的新进程会直接失败，旧进程不会退出
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+	srv.TLSConfig.Certificates = []tls.Certificate{cert}
+
+	ln, inherited, err := listener.Listen(srv.Addr)
+	if err != nil {
+		return err
+	}
+	if inherited {
+		app.infoLog.Printf("Starting server on inherited %s", ln.Addr())
+	} else {
+		app.infoLog.Printf("Starting server on %s", srv.Addr)
+	}
+
+	shutdownErr := make(chan error, 1)
+
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+		if listener.HandoffSignal != nil {
+			signal.Notify(quit, listener.HandoffSignal)
+		}
+
+		s := <-quit
+		for s == listener.HandoffSignal {
+			app.handOff(ln)
+			s = <-quit
+		}
+
+		// A second signal kills the process straight away, for when
+		// draining is taking too long.
+		// 第二个信号会直接结束进程，用于等待时间过长的情况
+		signal.Stop(quit)
+
+		app.infoLog.Printf("Shutting down server (%s), waiting up to %s for requests to finish", s, drainTimeout)
+
+		ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+		defer cancel()
+
+		err := srv.Shutdown(ctx)
+		if err != nil {
+			shutdownErr <- err
+			return
+		}
+
+		done := make(chan struct{})
+		go func() {
+			app.wg.Wait()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+			shutdownErr <- nil
+		case <-ctx.Done():
+			shutdownErr <- errors.New("gave up waiting for background tasks to finish")
+		}
+	}()
+
+	err = listener.Ready()
+	if err != nil {
+		app.errorLog.Print(err)
+	}
+
+	err = srv.ServeTLS(ln, "", "")
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return <-shutdownErr
+}
+
+// handOff starts a new process to take over ln, and logs if it exits, which
+// it will only do while this process is running if it failed.
+// 启动新进程接管 ln，新进程退出时写日志。只有新进程失败时，它才会在这个进程还在运行时退出
+func (app *application) handOff(ln net.Listener) {
+	p, err := listener.Handoff(ln)
+	if err != nil {
+		app.errorLog.Printf("Couldn't hand over the listener: %v", err)
+		return
+	}
+	app.infoLog.Printf("Handing over to process %d", p.Pid)
+
+	go func() {
+		state, err := p.Wait()
+		if err != nil {
+			app.errorLog.Print(err)
+			return
+		}
+		app.errorLog.Printf("Process %d exited before taking over: %s", p.Pid, state)
+	}()
+}
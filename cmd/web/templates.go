@@ -3,6 +3,7 @@ package main
 import (
 	"html/template" // New import
 	"path/filepath" // New import
+	"snippetbox.ab.net/internal/audit"
 	"snippetbox.ab.net/internal/models"
 	"time"
 )
@@ -14,6 +15,53 @@ type templateData struct {
 	Form            any
 	Flash           string
 	IsAuthenticated bool // 添加 IsAuthenticated 到 templateData struct 中
+	User            *models.User
+	OIDCName        string // 配置了单点登录时的身份提供方名称
+	Registration    string // 注册模式：open、invite 或 closed
+
+	// RecoveryCodes holds freshly generated two-factor recovery codes, which
+	// are only ever shown once.
+	// 新生成的两步验证恢复码，只展示一次
+	RecoveryCodes          []string
+	RecoveryCodesRemaining int
+
+	// Sessions lists the user's logged in devices. The current token is
+	// only compared against, never rendered.
+	// 用户已登录的设备列表，当前 token 只用于比较，不会渲染到页面上
+	Sessions            []*models.Session
+	CurrentSessionToken string
+
+	// Used by the admin pages. Query is the current search term.
+	// 管理页面使用，Query 是当前的搜索词
+	Users         []*models.User
+	LoginAttempts []*models.LoginAttempt
+	Query         string
+
+	// Used by the audit log page. AuditExportURL downloads the events
+	// matching the current filters as CSV.
+	// 审计日志页面使用，AuditExportURL 用于把符合当前筛选条件的事件下载为 CSV
+	AuditEvents    []*audit.Event
+	AuditActions   []string
+	AuditExportURL string
+
+	// Used by the account deletion page. IdentityLinked is whether the user
+	// has an external account they could confirm who they are with, and
+	// Reauthenticated whether they just have.
+	// 删除账号页面使用。IdentityLinked 表示用户是否有可以用来确认身份的外部账号，
+	// Reauthenticated 表示用户是否刚刚确认过
+	IdentityLinked  bool
+	Reauthenticated bool
+
+	// Profile is the user whose public profile is being shown.
+	// 正在展示公开主页的用户
+	Profile *models.User
+
+	// Invitations lists the invitations a user has sent. InvitationURL is a
+	// freshly created signup link, which is only ever shown once.
+	// 用户发出的邀请列表，InvitationURL 是新创建的注册链接，只展示一次
+	Invitations     []*models.Invitation
+	InvitationURL   string
+	InvitationsLeft int
 }
 
 func humanDate(t time.Time) string {
@@ -22,6 +70,7 @@ func humanDate(t time.Time) string {
 
 var functions = template.FuncMap{
 	"humanDate": humanDate,
+	"avatarURL": avatarURL,
 }
 
 func newTemplateCache() (map[string]*template.Template, error) {
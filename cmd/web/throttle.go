@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// loginThrottle describes how failed logins are slowed down. The first few
+// failures within the window are free, after that each further attempt has to
+// wait twice as long as the last (up to maxDelay), and once there have been
+// lockoutAfter failures no attempts are allowed for lockoutFor.
+// 登录限流策略：窗口内前几次失败不受限制，之后每次等待时间翻倍（最多 maxDelay），
+// 失败次数达到 lockoutAfter 后锁定 lockoutFor 时长
+type loginThrottle struct {
+	window       time.Duration
+	freeAttempts int
+	baseDelay    time.Duration
+	maxDelay     time.Duration
+	lockoutAfter int
+	lockoutFor   time.Duration
+}
+
+var (
+	// Per-account limits protect a single account from password guessing.
+	// 按账号限流，防止针对单个账号猜测密码
+	accountThrottle = loginThrottle{
+		window:       time.Hour,
+		freeAttempts: 3,
+		baseDelay:    time.Second,
+		maxDelay:     5 * time.Minute,
+		lockoutAfter: 10,
+		lockoutFor:   15 * time.Minute,
+	}
+
+	// Per-IP limits are looser, because many users can share an address, but
+	// stop one client from trying a password against many accounts.
+	// 按 IP 限流的限制更宽松，因为很多用户可能共享一个地址，但能阻止单个客户端尝试大量账号
+	ipThrottle = loginThrottle{
+		window:       time.Hour,
+		freeAttempts: 10,
+		baseDelay:    time.Second,
+		maxDelay:     5 * time.Minute,
+		lockoutAfter: 50,
+		lockoutFor:   time.Hour,
+	}
+)
+
+// wait returns how long the client must wait before another attempt is
+// allowed, given the number of recent failures and when the last one was.
+// 根据最近的失败次数和最后一次失败的时间，返回还需要等待多久才能再次尝试
+func (t loginThrottle) wait(failures int, last time.Time, now time.Time) time.Duration {
+	if failures < t.freeAttempts {
+		return 0
+	}
+
+	var delay time.Duration
+	if failures >= t.lockoutAfter {
+		delay = t.lockoutFor
+	} else {
+		delay = t.baseDelay
+		for i := t.freeAttempts; i < failures && delay < t.maxDelay; i++ {
+			delay *= 2
+		}
+		if delay > t.maxDelay {
+			delay = t.maxDelay
+		}
+	}
+
+	remaining := last.Add(delay).Sub(now)
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// loginWait checks both the account and the client IP, and returns the longer
+// of the two waits.
+// 同时检查账号和客户端 IP，返回两者中较长的等待时间
+func (app *application) loginWait(email, ip string) (time.Duration, error) {
+	now := time.Now().UTC()
+
+	failures, last, err := app.loginAttempts.FailuresForEmail(email, now.Add(-accountThrottle.window))
+	if err != nil {
+		return 0, err
+	}
+	wait := accountThrottle.wait(failures, last, now)
+
+	failures, last, err = app.loginAttempts.FailuresForIP(ip, now.Add(-ipThrottle.window))
+	if err != nil {
+		return 0, err
+	}
+	if ipWait := ipThrottle.wait(failures, last, now); ipWait > wait {
+		wait = ipWait
+	}
+
+	return wait, nil
+}
+
+// clientIP returns the IP address of the client without the port.
+// 返回客户端的 IP 地址（不包含端口）
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// humanDuration rounds d up to a friendly number of seconds or minutes.
+// 把时长向上取整为便于阅读的秒数或分钟数
+func humanDuration(d time.Duration) string {
+	if d <= time.Minute {
+		seconds := int((d + time.Second - 1) / time.Second)
+		if seconds == 1 {
+			return "1 second"
+		}
+		return fmt.Sprintf("%d seconds", seconds)
+	}
+
+	minutes := int((d + time.Minute - 1) / time.Minute)
+	return fmt.Sprintf("%d minutes", minutes)
+}
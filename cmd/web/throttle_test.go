@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLoginThrottleWait(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name     string
+		failures int
+		since    time.Duration // since the last failure
+		want     time.Duration
+	}{
+		{name: "No failures", failures: 0, want: 0},
+		{name: "Last free attempt", failures: 2, want: 0},
+		{name: "First delay", failures: 3, want: time.Second},
+		{name: "Doubles", failures: 4, want: 2 * time.Second},
+		{name: "Doubles again", failures: 5, want: 4 * time.Second},
+		{name: "Partly waited", failures: 5, since: 3 * time.Second, want: time.Second},
+		{name: "Fully waited", failures: 5, since: 10 * time.Second, want: 0},
+		{name: "Capped", failures: 9, want: time.Minute},
+		{name: "Locked out", failures: 10, want: 15 * time.Minute},
+		{name: "Lockout over", failures: 20, since: 15 * time.Minute, want: 0},
+	}
+
+	throttle := loginThrottle{
+		window:       time.Hour,
+		freeAttempts: 3,
+		baseDelay:    time.Second,
+		maxDelay:     time.Minute,
+		lockoutAfter: 10,
+		lockoutFor:   15 * time.Minute,
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := throttle.wait(tt.failures, now.Add(-tt.since), now)
+			if got != tt.want {
+				t.Errorf("got %s; want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHumanDuration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{time.Millisecond, "1 second"},
+		{time.Second, "1 second"},
+		{1500 * time.Millisecond, "2 seconds"},
+		{time.Minute, "60 seconds"},
+		{time.Minute + time.Second, "2 minutes"},
+		{15 * time.Minute, "15 minutes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.d.String(), func(t *testing.T) {
+			got := humanDuration(tt.d)
+			if got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		remoteAddr string
+		want       string
+	}{
+		{"192.0.2.1:1234", "192.0.2.1"},
+		{"[2001:db8::1]:1234", "2001:db8::1"},
+		{"192.0.2.1", "192.0.2.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.remoteAddr, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			got := clientIP(r)
+			if got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
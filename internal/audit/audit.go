@@ -0,0 +1,211 @@
+// Package audit records security-relevant events, such as logins and
+// deletions, so that administrators can find out who did what and when.
+package audit
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"io"
+	"snippetbox.ab.net/internal/database"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Define the actions which are recorded. Actions are named
+// "<thing>.<what happened>" so that related ones sort together.
+// 记录的操作，命名为 "<对象>.<发生了什么>"，相关的操作排序时会在一起
+const (
+	LoginSuccess        = "login.success"
+	LoginFailure        = "login.failure"
+	Logout              = "logout"
+	Signup              = "signup"
+	PasswordChange      = "password.change"
+	TwoFactorEnable     = "2fa.enable"
+	TwoFactorDisable    = "2fa.disable"
+	SessionRevoke       = "session.revoke"
+	AccountDelete       = "account.delete"
+	AccountDisable      = "account.disable"
+	AccountEnable       = "account.enable"
+	SnippetCreate       = "snippet.create"
+	SnippetDelete       = "snippet.delete"
+	InvitationCreate    = "invitation.create"
+	AccountHandleChange = "account.handle"
+)
+
+// Actions lists every action, for filter menus.
+// 所有的操作，用于筛选菜单
+var Actions = []string{
+	TwoFactorDisable, TwoFactorEnable, AccountDelete, AccountDisable,
+	AccountEnable, AccountHandleChange, InvitationCreate, LoginFailure,
+	LoginSuccess, Logout, PasswordChange, SessionRevoke, Signup,
+	SnippetCreate, SnippetDelete,
+}
+
+// Event is one thing that happened. ActorID is the user who did it, or 0 if
+// they weren't logged in. Subject is what it was done to, like an email
+// address or "snippet:12".
+// 一个事件。ActorID 是执行操作的用户，未登录时为 0；Subject 是操作的对象，比如邮箱或 "snippet:12"
+type Event struct {
+	ID        int
+	Action    string
+	ActorID   int
+	Subject   string
+	IP        string
+	UserAgent string
+	Detail    string
+	Created   time.Time
+}
+
+// Filter narrows down a listing. Zero fields don't filter anything.
+// 列表的筛选条件，零值表示不筛选
+type Filter struct {
+	Action  string
+	ActorID int
+	IP      string
+	From    time.Time
+	To      time.Time
+}
+
+// Log wraps the audit_events table. Events are never updated, and they don't
+// reference users with a foreign key so that they outlive deleted accounts.
+// 审计日志，事件永远不会被修改，也不通过外键关联用户，这样账号删除之后事件仍然保留
+type Log struct {
+	DB *database.DB
+}
+
+// truncate cuts s to at most n bytes without splitting a UTF-8 sequence.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	s = s[:n]
+	for len(s) > 0 && !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+	return s
+}
+
+// Record saves an event.
+// 保存一个事件
+func (l *Log) Record(e Event) error {
+	stmt := `INSERT INTO audit_events (action, actor_id, subject, ip, user_agent, detail, created)
+    VALUES(?, ?, ?, ?, ?, ?, ?)`
+
+	var actorID sql.NullInt64
+	if e.ActorID != 0 {
+		actorID = sql.NullInt64{Int64: int64(e.ActorID), Valid: true}
+	}
+
+	_, err := l.DB.Exec(stmt, e.Action, actorID, truncate(e.Subject, 255), truncate(e.IP, 45),
+		truncate(e.UserAgent, 512), truncate(e.Detail, 255), time.Now().UTC())
+	return err
+}
+
+// List returns up to limit events matching the filter, newest first.
+// 返回符合筛选条件的事件（最多 limit 条），按时间倒序
+func (l *Log) List(f Filter, limit int) ([]*Event, error) {
+	var where []string
+	var args []any
+
+	if f.Action != "" {
+		where = append(where, "action = ?")
+		args = append(args, f.Action)
+	}
+	if f.ActorID != 0 {
+		where = append(where, "actor_id = ?")
+		args = append(args, f.ActorID)
+	}
+	if f.IP != "" {
+		where = append(where, "ip = ?")
+		args = append(args, f.IP)
+	}
+	if !f.From.IsZero() {
+		where = append(where, "created >= ?")
+		args = append(args, f.From.UTC())
+	}
+	if !f.To.IsZero() {
+		where = append(where, "created < ?")
+		args = append(args, f.To.UTC())
+	}
+
+	stmt := `SELECT id, action, actor_id, subject, ip, user_agent, detail, created FROM audit_events`
+	if len(where) > 0 {
+		stmt += " WHERE " + strings.Join(where, " AND ")
+	}
+	stmt += " ORDER BY id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := l.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []*Event{}
+
+	for rows.Next() {
+		e := &Event{}
+		var actorID sql.NullInt64
+
+		err = rows.Scan(&e.ID, &e.Action, &actorID, &e.Subject, &e.IP, &e.UserAgent, &e.Detail, &e.Created)
+		if err != nil {
+			return nil, err
+		}
+		e.ActorID = int(actorID.Int64)
+
+		events = append(events, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// csvSafe stops a spreadsheet from treating a value as a formula, since
+// subjects and user agents are chosen by whoever made the request.
+// 防止电子表格把值当作公式执行，因为 subject 和 user agent 是由请求者控制的
+func csvSafe(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// WriteCSV writes events as CSV with a header row.
+// 把事件写成带表头的 CSV
+func WriteCSV(w io.Writer, events []*Event) error {
+	cw := csv.NewWriter(w)
+
+	err := cw.Write([]string{"id", "time", "action", "actor_id", "subject", "ip", "user_agent", "detail"})
+	if err != nil {
+		return err
+	}
+
+	for _, e := range events {
+		actor := ""
+		if e.ActorID != 0 {
+			actor = strconv.Itoa(e.ActorID)
+		}
+
+		err = cw.Write([]string{
+			strconv.Itoa(e.ID),
+			e.Created.UTC().Format(time.RFC3339),
+			e.Action,
+			actor,
+			csvSafe(e.Subject),
+			csvSafe(e.IP),
+			csvSafe(e.UserAgent),
+			csvSafe(e.Detail),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,199 @@
+// Package avatar turns uploaded pictures into square avatars of fixed sizes,
+// and generates identicons for users who haven't uploaded one.
+package avatar
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+
+	// Register the formats we accept with image.Decode.
+	// 注册 image.Decode 支持的格式
+	_ "image/gif"
+	_ "image/jpeg"
+)
+
+// Sizes are the avatar sizes, in pixels, that are generated and served.
+// 生成和提供的头像尺寸（像素）
+var Sizes = []int{64, 256}
+
+const (
+	// maxDimension limits the width and height of an upload, so that a
+	// small file claiming to be a huge image can't exhaust our memory.
+	// 限制上传图片的宽高，防止声称是巨大图片的小文件耗尽内存
+	maxDimension = 4096
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("avatar: unsupported image format")
+	ErrTooLarge          = errors.New("avatar: image dimensions too large")
+)
+
+// ValidSize reports whether size is one of Sizes.
+func ValidSize(size int) bool {
+	for _, s := range Sizes {
+		if s == size {
+			return true
+		}
+	}
+	return false
+}
+
+// Process decodes an uploaded PNG, JPEG or GIF (only the first frame of an
+// animated GIF is used), crops it to a square from the centre and returns it
+// resized to each of Sizes as PNG.
+// 解码上传的 PNG、JPEG 或 GIF（动图只使用第一帧），从中心裁剪成正方形，缩放成 Sizes 中的每个尺寸并编码为 PNG
+func Process(r io.Reader) (map[int][]byte, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the header before decoding the whole image.
+	// 解码整张图片之前先检查头部信息
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedFormat
+	}
+	switch format {
+	case "png", "jpeg", "gif":
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+	if config.Width > maxDimension || config.Height > maxDimension {
+		return nil, ErrTooLarge
+	}
+	if config.Width < 1 || config.Height < 1 {
+		return nil, ErrUnsupportedFormat
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedFormat, err)
+	}
+
+	square := cropSquare(img)
+
+	out := map[int][]byte{}
+	for _, size := range Sizes {
+		var buf bytes.Buffer
+		err = png.Encode(&buf, resize(square, size))
+		if err != nil {
+			return nil, err
+		}
+		out[size] = buf.Bytes()
+	}
+
+	return out, nil
+}
+
+// cropSquare returns the largest square in the centre of img, as RGBA.
+// 返回 img 中心最大的正方形区域，转换成 RGBA
+func cropSquare(img image.Image) *image.RGBA {
+	b := img.Bounds()
+	side := b.Dx()
+	if b.Dy() < side {
+		side = b.Dy()
+	}
+
+	x0 := b.Min.X + (b.Dx()-side)/2
+	y0 := b.Min.Y + (b.Dy()-side)/2
+
+	dst := image.NewRGBA(image.Rect(0, 0, side, side))
+	draw.Draw(dst, dst.Bounds(), img, image.Pt(x0, y0), draw.Src)
+	return dst
+}
+
+// resize scales a square image to size x size. Each destination pixel is the
+// average of the source pixels it covers, which gives clean results when
+// shrinking; when enlarging it comes down to nearest neighbour.
+// 把正方形图片缩放到 size x size。每个目标像素取它覆盖的源像素的平均值，缩小时效果很好，放大时相当于最近邻插值
+func resize(src *image.RGBA, size int) *image.RGBA {
+	side := src.Bounds().Dx()
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+
+	for dy := 0; dy < size; dy++ {
+		sy0 := dy * side / size
+		sy1 := (dy + 1) * side / size
+		if sy1 <= sy0 {
+			sy1 = sy0 + 1
+		}
+
+		for dx := 0; dx < size; dx++ {
+			sx0 := dx * side / size
+			sx1 := (dx + 1) * side / size
+			if sx1 <= sx0 {
+				sx1 = sx0 + 1
+			}
+
+			var r, g, b, a, n uint64
+			for sy := sy0; sy < sy1; sy++ {
+				i := src.PixOffset(sx0, sy)
+				for sx := sx0; sx < sx1; sx++ {
+					r += uint64(src.Pix[i])
+					g += uint64(src.Pix[i+1])
+					b += uint64(src.Pix[i+2])
+					a += uint64(src.Pix[i+3])
+					i += 4
+					n++
+				}
+			}
+
+			j := dst.PixOffset(dx, dy)
+			dst.Pix[j] = uint8(r / n)
+			dst.Pix[j+1] = uint8(g / n)
+			dst.Pix[j+2] = uint8(b / n)
+			dst.Pix[j+3] = uint8(a / n)
+		}
+	}
+
+	return dst
+}
+
+// Identicon returns a PNG of a symmetric 5x5 pattern derived from the user
+// ID, so that every user without an avatar still gets a distinctive one.
+// 根据用户 ID 生成对称的 5x5 图案 PNG，让没有上传头像的用户也有一个可以区分的头像
+func Identicon(userID int, size int) ([]byte, error) {
+	var id [8]byte
+	binary.BigEndian.PutUint64(id[:], uint64(userID))
+	sum := sha256.Sum256(id[:])
+
+	fg := color.RGBA{R: 40 + sum[0]%160, G: 40 + sum[1]%160, B: 40 + sum[2]%160, A: 255}
+	bg := color.RGBA{R: 240, G: 240, B: 240, A: 255}
+
+	img := image.NewPaletted(image.Rect(0, 0, size, size), color.Palette{bg, fg})
+
+	// The pattern is 5 cells wide with half a cell of margin either side.
+	// 图案宽 5 个格子，两边各留半个格子的边距
+	cell := size / 6
+	margin := (size - cell*5) / 2
+
+	for row := 0; row < 5; row++ {
+		for col := 0; col < 3; col++ {
+			// One bit per cell in the left half, mirrored to the right.
+			// 左半边每个格子对应一个比特，右半边镜像
+			bit := row*3 + col
+			if sum[3+bit/8]>>(bit%8)&1 == 0 {
+				continue
+			}
+			for _, c := range []int{col, 4 - col} {
+				r := image.Rect(margin+c*cell, margin+row*cell, margin+(c+1)*cell, margin+(row+1)*cell)
+				draw.Draw(img, r, image.NewUniform(fg), image.Point{}, draw.Src)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	err := png.Encode(&buf, img)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,118 @@
+// Package cache provides a size-bounded least recently used cache whose
+// entries also expire.
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// LRU holds up to a fixed number of entries, dropping the least recently
+// used one to make room for a new one. Each entry has its own expiry time,
+// after which it's as if it had never been added. It's safe for concurrent
+// use.
+// 最多保存固定数量的条目，空间不够时删除最久没有使用的条目。每个条目都有自己的过期时间，
+// 过期之后就像从来没有添加过一样。可以并发使用
+type LRU[K comparable, V any] struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List // front is the most recently used
+	items map[K]*list.Element
+}
+
+type entry[K comparable, V any] struct {
+	key     K
+	value   V
+	expires time.Time
+}
+
+// New returns an empty cache which holds up to size entries.
+// 返回一个最多保存 size 个条目的空缓存
+func New[K comparable, V any](size int) *LRU[K, V] {
+	return &LRU[K, V]{
+		size:  size,
+		order: list.New(),
+		items: map[K]*list.Element{},
+	}
+}
+
+// Get returns the value for key, if it's there and hasn't expired.
+// 返回 key 对应的值（如果存在且未过期）
+func (c *LRU[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var zero V
+
+	el, ok := c.items[key]
+	if !ok {
+		return zero, false
+	}
+
+	e := el.Value.(*entry[K, V])
+	if !time.Now().Before(e.expires) {
+		c.remove(el)
+		return zero, false
+	}
+
+	c.order.MoveToFront(el)
+	return e.value, true
+}
+
+// Add stores a value for key until expires, replacing any value already
+// there.
+// 保存 key 对应的值直到 expires，替换已有的值
+func (c *LRU[K, V]) Add(key K, value V, expires time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*entry[K, V])
+		e.value, e.expires = value, expires
+		c.order.MoveToFront(el)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&entry[K, V]{key: key, value: value, expires: expires})
+
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+}
+
+// Remove deletes key's entry, if there is one.
+// 删除 key 对应的条目（如果存在）
+func (c *LRU[K, V]) Remove(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+}
+
+// Purge deletes every entry.
+// 删除所有条目
+func (c *LRU[K, V]) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.order.Init()
+	c.items = map[K]*list.Element{}
+}
+
+// Len returns the number of entries, including any which have expired but
+// haven't been noticed yet.
+// 返回条目数量，包括已经过期但还没有被发现的条目
+func (c *LRU[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.order.Len()
+}
+
+func (c *LRU[K, V]) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.items, el.Value.(*entry[K, V]).key)
+}
@@ -0,0 +1,112 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLRU(t *testing.T) {
+	later := time.Now().Add(time.Hour)
+	past := time.Now().Add(-time.Second)
+
+	tests := []struct {
+		name string
+		run  func(c *LRU[string, int])
+		want map[string]int // what Get finds afterwards, of keys a to d
+	}{
+		{
+			name: "Within size",
+			run: func(c *LRU[string, int]) {
+				c.Add("a", 1, later)
+				c.Add("b", 2, later)
+			},
+			want: map[string]int{"a": 1, "b": 2},
+		},
+		{
+			name: "Evicts the oldest",
+			run: func(c *LRU[string, int]) {
+				c.Add("a", 1, later)
+				c.Add("b", 2, later)
+				c.Add("c", 3, later)
+				c.Add("d", 4, later)
+			},
+			want: map[string]int{"b": 2, "c": 3, "d": 4},
+		},
+		{
+			name: "Get makes an entry recent",
+			run: func(c *LRU[string, int]) {
+				c.Add("a", 1, later)
+				c.Add("b", 2, later)
+				c.Add("c", 3, later)
+				c.Get("a")
+				c.Add("d", 4, later)
+			},
+			want: map[string]int{"a": 1, "c": 3, "d": 4},
+		},
+		{
+			name: "Add replaces and makes recent",
+			run: func(c *LRU[string, int]) {
+				c.Add("a", 1, later)
+				c.Add("b", 2, later)
+				c.Add("c", 3, later)
+				c.Add("a", 10, later)
+				c.Add("d", 4, later)
+			},
+			want: map[string]int{"a": 10, "c": 3, "d": 4},
+		},
+		{
+			name: "Expired",
+			run: func(c *LRU[string, int]) {
+				c.Add("a", 1, past)
+				c.Add("b", 2, later)
+			},
+			want: map[string]int{"b": 2},
+		},
+		{
+			name: "Replaced expiry",
+			run: func(c *LRU[string, int]) {
+				c.Add("a", 1, later)
+				c.Add("a", 1, past)
+			},
+			want: map[string]int{},
+		},
+		{
+			name: "Remove",
+			run: func(c *LRU[string, int]) {
+				c.Add("a", 1, later)
+				c.Add("b", 2, later)
+				c.Remove("a")
+				c.Remove("z")
+			},
+			want: map[string]int{"b": 2},
+		},
+		{
+			name: "Purge",
+			run: func(c *LRU[string, int]) {
+				c.Add("a", 1, later)
+				c.Add("b", 2, later)
+				c.Purge()
+				c.Add("c", 3, later)
+			},
+			want: map[string]int{"c": 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New[string, int](3)
+			tt.run(c)
+
+			for _, key := range []string{"a", "b", "c", "d"} {
+				got, ok := c.Get(key)
+				want, wantOK := tt.want[key]
+				if ok != wantOK || got != want {
+					t.Errorf("Get(%q): got (%d, %t); want (%d, %t)", key, got, ok, want, wantOK)
+				}
+			}
+			if c.Len() != len(tt.want) {
+				t.Errorf("got Len %d; want %d", c.Len(), len(tt.want))
+			}
+		})
+	}
+}
@@ -0,0 +1,356 @@
+// Package config lets every command line flag also be set from a
+// configuration file or an environment variable. A flag on the command line
+// beats an environment variable, which beats the file, which beats the
+// flag's default.
+//
+// A setting's environment variable is its flag name in upper case, with
+// dashes turned into underscores and a prefix, so -db-max-open-conns is
+// SNIPPETBOX_DB_MAX_OPEN_CONNS. In a file, settings are named after their
+// flags too, and nested tables are joined with dashes, so this TOML
+//
+//	addr = ":443"
+//
+//	[db]
+//	max-open-conns = 50
+//
+// sets -addr and -db-max-open-conns. Underscores may be used instead of
+// dashes.
+//
+// 让每个命令行参数也可以通过配置文件或环境变量设置。命令行参数优先于环境变量，
+// 环境变量优先于配置文件，配置文件优先于参数的默认值。
+//
+// 环境变量的名字是参数名转成大写、短横线换成下划线再加上前缀，所以 -db-max-open-conns
+// 对应 SNIPPETBOX_DB_MAX_OPEN_CONNS。配置文件中的设置也以参数命名，嵌套的表用短横线连接，
+// 所以上面的 TOML 设置了 -addr 和 -db-max-open-conns。也可以用下划线代替短横线
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config loads the settings of a FlagSet.
+// 加载 FlagSet 的设置
+type Config struct {
+	flags  *flag.FlagSet
+	prefix string
+
+	path  *string
+	print *bool
+
+	sources map[string]string // where each setting that isn't a default came from
+	secrets map[string]func(string) string
+}
+
+// New returns a Config for flags, with environment variables starting with
+// prefix and an underscore. It adds a -config flag naming the file to load,
+// which can also be set with the environment, and a -print-config flag.
+// 返回 flags 的 Config，环境变量以 prefix 加下划线开头。它会添加指定配置文件的 -config 参数
+// （也可以通过环境变量设置）和 -print-config 参数
+func New(flags *flag.FlagSet, prefix string) *Config {
+	c := &Config{
+		flags:   flags,
+		prefix:  prefix + "_",
+		sources: map[string]string{},
+		secrets: map[string]func(string) string{},
+	}
+
+	c.path = flags.String("config", "", "Configuration file (.toml, .yaml or .json)")
+	c.print = flags.Bool("print-config", false, "Print the effective configuration, with secrets redacted, and exit")
+
+	return c
+}
+
+// Secret marks a setting as secret, so that Print doesn't show it. If
+// redact isn't nil, Print shows what it returns instead of the value, so
+// that for example a database DSN can be shown without its password.
+// 把设置标记为机密，Print 不会显示它。如果 redact 不为 nil，Print 显示 redact 的返回值，
+// 比如显示去掉密码的数据库 DSN
+func (c *Config) Secret(name string, redact func(string) string) {
+	c.secrets[name] = redact
+}
+
+// Load parses the command line arguments, then sets each flag which wasn't
+// on the command line from environ, in the format of os.Environ, or failing
+// that from the configuration file. It reports unknown settings and bad
+// values along with where they came from.
+// 解析命令行参数，然后对于命令行上没有的参数，从 environ（os.Environ 的格式）设置，
+// 如果环境变量中也没有则从配置文件设置。未知的设置和错误的值会连同来源一起报告
+func (c *Config) Load(args []string, environ []string) error {
+	err := c.flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	c.flags.Visit(func(f *flag.Flag) {
+		c.sources[f.Name] = "the -" + f.Name + " flag"
+	})
+
+	env := map[string]string{}
+	for _, kv := range environ {
+		k, v, _ := strings.Cut(kv, "=")
+		if strings.HasPrefix(k, c.prefix) {
+			env[k] = v
+		}
+	}
+
+	path := *c.path
+	if _, ok := c.sources["config"]; !ok && env[c.envName("config")] != "" {
+		path = env[c.envName("config")]
+	}
+
+	values := map[string]setting{}
+
+	if path != "" {
+		settings, err := readFile(path)
+		if err != nil {
+			return err
+		}
+
+		for key, value := range settings {
+			name := strings.ReplaceAll(key, "_", "-")
+			if c.flags.Lookup(name) == nil || name == "config" {
+				return fmt.Errorf("%s: unknown setting %q", path, key)
+			}
+			values[name] = setting{value: value, source: path}
+		}
+	}
+
+	for k, v := range env {
+		name := strings.ToLower(strings.ReplaceAll(strings.TrimPrefix(k, c.prefix), "_", "-"))
+		if c.flags.Lookup(name) == nil {
+			return fmt.Errorf("%s: unknown setting %q", k, name)
+		}
+		values[name] = setting{value: v, source: k}
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if _, ok := c.sources[name]; ok {
+			continue
+		}
+
+		s := values[name]
+		err := c.flags.Set(name, s.value)
+		if err != nil {
+			return fmt.Errorf("%s: invalid value %q for %s: %s", s.source, s.value, name, expected(c.flags.Lookup(name), err))
+		}
+		c.sources[name] = s.source
+	}
+
+	if *c.path == "" {
+		*c.path = path
+	}
+
+	return nil
+}
+
+type setting struct {
+	value  string
+	source string
+}
+
+// PrintRequested reports whether -print-config was given.
+// 是否指定了 -print-config
+func (c *Config) PrintRequested() bool {
+	return *c.print
+}
+
+// Print writes the effective configuration to w as TOML, which can be used
+// as a configuration file. Settings which aren't defaults are commented
+// with where they came from.
+// 把生效的配置以 TOML 格式写入 w，输出可以作为配置文件使用。不是默认值的设置会注释说明来源
+func (c *Config) Print(w io.Writer) error {
+	var buf bytes.Buffer
+
+	c.flags.VisitAll(func(f *flag.Flag) {
+		if f.Name == "print-config" {
+			return
+		}
+
+		value := f.Value.String()
+		if redact, ok := c.secrets[f.Name]; ok && value != "" {
+			if redact != nil {
+				value = redact(value)
+			} else {
+				value = "REDACTED"
+			}
+		}
+
+		// Numbers and booleans are written bare, everything else (including
+		// durations) as a string.
+		// 数字和布尔值直接写出，其他值（包括时长）写成字符串
+		quote := true
+		if g, ok := f.Value.(flag.Getter); ok {
+			switch g.Get().(type) {
+			case bool, int, int64, uint, uint64, float64:
+				quote = false
+			}
+		}
+		if quote {
+			value = strconv.Quote(value)
+		}
+
+		fmt.Fprintf(&buf, "%s = %s", f.Name, value)
+		if source, ok := c.sources[f.Name]; ok {
+			fmt.Fprintf(&buf, " # from %s", source)
+		}
+		buf.WriteByte('\n')
+	})
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// Invalid returns an error saying that the value of the named setting is
+// wrong, and where the value came from.
+// 返回一个错误，说明指定设置的值有误，以及这个值的来源
+func (c *Config) Invalid(name, reason string) error {
+	source, ok := c.sources[name]
+	if !ok {
+		source = "the default"
+	}
+
+	value := c.flags.Lookup(name).Value.String()
+	if _, ok := c.secrets[name]; ok {
+		value = "REDACTED"
+	}
+
+	return fmt.Errorf("invalid %s %q from %s: %s", name, value, source, reason)
+}
+
+// CheckNonNegative returns an Invalid error for the first of the named
+// numeric or duration settings which is negative.
+// 对指定的数值或时长设置中第一个为负数的设置返回 Invalid 错误
+func (c *Config) CheckNonNegative(names ...string) error {
+	for _, name := range names {
+		negative := false
+
+		g, ok := c.flags.Lookup(name).Value.(flag.Getter)
+		if !ok {
+			continue
+		}
+
+		switch v := g.Get().(type) {
+		case int:
+			negative = v < 0
+		case int64:
+			negative = v < 0
+		case time.Duration:
+			negative = v < 0
+		case float64:
+			negative = v < 0
+		}
+
+		if negative {
+			return c.Invalid(name, "can't be negative")
+		}
+	}
+
+	return nil
+}
+
+// expected describes the kind of value the flag f needs, since the flag
+// package's own errors just say "parse error".
+// 描述参数 f 需要的值的类型，因为 flag 包自己的错误只会说 "parse error"
+func expected(f *flag.Flag, err error) string {
+	if g, ok := f.Value.(flag.Getter); ok {
+		switch g.Get().(type) {
+		case bool:
+			return "must be true or false"
+		case int, int64:
+			return "must be a whole number"
+		case uint, uint64:
+			return "must be a whole number, not negative"
+		case float64:
+			return "must be a number"
+		case time.Duration:
+			return "must be a duration such as 30s, 15m or 1h"
+		}
+	}
+	return err.Error()
+}
+
+func (c *Config) envName(name string) string {
+	return c.prefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// readFile reads a configuration file in the format given by its
+// extension, and returns its settings with nested tables flattened.
+// 按照扩展名对应的格式读取配置文件，返回展开嵌套表之后的设置
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := map[string]any{}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".toml":
+		err = toml.Unmarshal(data, &doc)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &doc)
+	case ".json":
+		err = json.Unmarshal(data, &doc)
+	default:
+		return nil, fmt.Errorf("%s: unknown configuration file format %q (use .toml, .yaml or .json)", path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	settings := map[string]string{}
+	err = flatten(settings, "", doc)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return settings, nil
+}
+
+func flatten(settings map[string]string, prefix string, doc map[string]any) error {
+	for k, v := range doc {
+		key := prefix + k
+
+		switch v := v.(type) {
+		case map[string]any:
+			err := flatten(settings, key+"-", v)
+			if err != nil {
+				return err
+			}
+		case string:
+			settings[key] = v
+		case bool:
+			settings[key] = strconv.FormatBool(v)
+		case int:
+			settings[key] = strconv.Itoa(v)
+		case int64:
+			settings[key] = strconv.FormatInt(v, 10)
+		case uint64:
+			settings[key] = strconv.FormatUint(v, 10)
+		case float64:
+			settings[key] = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			return fmt.Errorf("%s must be a string, number or boolean", key)
+		}
+	}
+
+	return nil
+}
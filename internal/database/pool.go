@@ -0,0 +1,83 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Pool is the connection pool's configuration. Zero values leave
+// database/sql's defaults alone: no limit on open connections, two idle
+// ones, and connections kept for ever.
+// 连接池配置，零值表示使用 database/sql 的默认值：不限制打开的连接数，保留两个空闲连接，连接永不过期
+type Pool struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+func (p Pool) apply(db *sql.DB) {
+	if p.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(p.MaxOpenConns)
+	}
+	if p.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(p.MaxIdleConns)
+	}
+	if p.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(p.ConnMaxLifetime)
+	}
+	if p.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(p.ConnMaxIdleTime)
+	}
+}
+
+// Retry says how long to keep trying to reach the database at startup, for
+// when it's started at the same time as us. The wait between attempts
+// doubles from Delay up to MaxDelay.
+// 启动时持续尝试连接数据库的方式，用于数据库和应用同时启动的情况。每次尝试之间的等待时间从 Delay 开始加倍，最多到 MaxDelay
+type Retry struct {
+	Attempts int
+	Delay    time.Duration
+	MaxDelay time.Duration
+}
+
+// Connect opens the database like Open, with the given pool configuration,
+// pinging it up to retry.Attempts times before giving up. Each failure is
+// passed to logf along with the wait before the next attempt.
+// 和 Open 一样打开数据库并使用给定的连接池配置，最多 ping retry.Attempts 次后放弃。
+// 每次失败都会连同下次尝试前的等待时间一起传给 logf
+func Connect(ctx context.Context, dsn string, pool Pool, retry Retry, logf func(format string, v ...any)) (*DB, error) {
+	db, err := open(dsn, pool)
+	if err != nil {
+		return nil, err
+	}
+
+	delay := retry.Delay
+	for attempt := 1; ; attempt++ {
+		err = db.PingContext(ctx)
+		if err == nil {
+			return db, nil
+		}
+		if attempt >= retry.Attempts {
+			break
+		}
+
+		logf("Couldn't reach the database (attempt %d of %d), retrying in %s: %v", attempt, retry.Attempts, delay, err)
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			db.Close()
+			return nil, ctx.Err()
+		}
+
+		delay *= 2
+		if delay > retry.MaxDelay {
+			delay = retry.MaxDelay
+		}
+	}
+
+	db.Close()
+	return nil, err
+}
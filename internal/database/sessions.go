@@ -0,0 +1,86 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// SessionStore keeps scs session data in the sessions table of a PostgreSQL
+// or SQLite database, like scs's mysqlstore does for MySQL. Expired sessions
+// are deleted every cleanup interval until StopCleanup is called, and errors
+// doing so are reported with logf.
+// 把 scs 的 session 数据保存在 PostgreSQL 或 SQLite 数据库的 sessions 表中，和 scs 的 mysqlstore 对 MySQL 做的一样。
+// 每隔 cleanup interval 删除一次过期的 session，直到调用 StopCleanup，删除时的错误通过 logf 报告
+type SessionStore struct {
+	db          *DB
+	logf        func(format string, v ...any)
+	stopCleanup chan struct{}
+}
+
+func NewSessionStore(db *DB, cleanupInterval time.Duration, logf func(format string, v ...any)) *SessionStore {
+	s := &SessionStore{db: db, logf: logf, stopCleanup: make(chan struct{})}
+
+	if cleanupInterval > 0 {
+		go s.cleanup(cleanupInterval)
+	}
+
+	return s
+}
+
+// Find returns the data for a session token, if it exists and hasn't
+// expired.
+// 返回 session token 对应的数据（如果存在且未过期）
+func (s *SessionStore) Find(token string) ([]byte, bool, error) {
+	var data []byte
+
+	err := s.db.QueryRow(`SELECT data FROM sessions WHERE token = ? AND expiry > ?`, token, time.Now().UTC()).Scan(&data)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+
+	return data, true, nil
+}
+
+// Commit adds or replaces a session.
+// 添加或替换一个 session
+func (s *SessionStore) Commit(token string, data []byte, expiry time.Time) error {
+	stmt := `INSERT INTO sessions (token, data, expiry) VALUES (?, ?, ?)
+    ON CONFLICT (token) DO UPDATE SET data = excluded.data, expiry = excluded.expiry`
+
+	_, err := s.db.Exec(stmt, token, data, expiry.UTC())
+	return err
+}
+
+// Delete removes a session.
+// 删除一个 session
+func (s *SessionStore) Delete(token string) error {
+	_, err := s.db.Exec(`DELETE FROM sessions WHERE token = ?`, token)
+	return err
+}
+
+func (s *SessionStore) cleanup(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			_, err := s.db.Exec(`DELETE FROM sessions WHERE expiry < ?`, time.Now().UTC())
+			if err != nil {
+				s.logf("Couldn't delete expired sessions: %v", err)
+			}
+		case <-s.stopCleanup:
+			return
+		}
+	}
+}
+
+// StopCleanup stops the background cleanup.
+// 停止后台清理
+func (s *SessionStore) StopCleanup() {
+	close(s.stopCleanup)
+}
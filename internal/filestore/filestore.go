@@ -0,0 +1,203 @@
+// Package filestore stores uploaded files, such as avatars, behind a small
+// interface so that where they live can be changed without touching the code
+// which uses them.
+package filestore
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNotFound   = errors.New("filestore: file not found")
+	ErrInvalidKey = errors.New("filestore: invalid key")
+)
+
+// Store is implemented by anything which can keep files. Keys are
+// slash-separated relative paths like "avatars/1/2-64.png".
+// Store 接口，key 是用斜杠分隔的相对路径，比如 "avatars/1/2-64.png"
+type Store interface {
+	Put(key string, data []byte) error
+	Open(key string) (io.ReadSeekCloser, time.Time, error)
+	Delete(key string) error
+}
+
+// checkKey rejects keys which could escape the store, like "../x" or "/x".
+// 拒绝可能逃出存储目录的 key，比如 "../x" 或 "/x"
+func checkKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") || path.Clean(key) != key || strings.HasPrefix(key, "..") {
+		return ErrInvalidKey
+	}
+	return nil
+}
+
+// DiskStore keeps files under a directory on the local disk.
+// 把文件保存在本地磁盘的目录中
+type DiskStore struct {
+	Dir string
+}
+
+// NewDisk returns a DiskStore, creating dir if it doesn't exist.
+func NewDisk(dir string) (*DiskStore, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, err
+	}
+	return &DiskStore{Dir: dir}, nil
+}
+
+func (s *DiskStore) path(key string) (string, error) {
+	err := checkKey(key)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(s.Dir, filepath.FromSlash(key)), nil
+}
+
+// Put writes the file to a temporary name first and then renames it, so that
+// readers never see a half written file.
+// 先写入临时文件再重命名，读取的一方永远不会看到写了一半的文件
+func (s *DiskStore) Put(key string, data []byte) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(name), 0o755)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(name), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(data)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), name)
+}
+
+func (s *DiskStore) Open(key string) (io.ReadSeekCloser, time.Time, error) {
+	name, err := s.path(key)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, time.Time{}, ErrNotFound
+		}
+		return nil, time.Time{}, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, time.Time{}, err
+	}
+
+	return f, info.ModTime(), nil
+}
+
+func (s *DiskStore) Delete(key string) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(name)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// MemoryStore keeps files in memory. It's useful for development and
+// testing; everything is lost when the process exits.
+// 把文件保存在内存中，适合开发和测试，进程退出后所有文件都会丢失
+type MemoryStore struct {
+	mu    sync.RWMutex
+	files map[string]memoryFile
+}
+
+type memoryFile struct {
+	data     []byte
+	modified time.Time
+}
+
+func NewMemory() *MemoryStore {
+	return &MemoryStore{files: map[string]memoryFile{}}
+}
+
+func (s *MemoryStore) Put(key string, data []byte) error {
+	err := checkKey(key)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.files[key] = memoryFile{data: bytes.Clone(data), modified: time.Now()}
+	return nil
+}
+
+func (s *MemoryStore) Open(key string) (io.ReadSeekCloser, time.Time, error) {
+	err := checkKey(key)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	f, ok := s.files[key]
+	if !ok {
+		return nil, time.Time{}, ErrNotFound
+	}
+
+	return nopCloser{bytes.NewReader(f.data)}, f.modified, nil
+}
+
+func (s *MemoryStore) Delete(key string) error {
+	err := checkKey(key)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.files, key)
+	return nil
+}
+
+type nopCloser struct {
+	io.ReadSeeker
+}
+
+func (nopCloser) Close() error { return nil }
+
+// Make sure both stores satisfy the interface at compile time.
+var (
+	_ Store = (*DiskStore)(nil)
+	_ Store = (*MemoryStore)(nil)
+)
@@ -0,0 +1,102 @@
+package filestore
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestCheckKey(t *testing.T) {
+	tests := []struct {
+		key   string
+		valid bool
+	}{
+		{"avatars/1/2-64.png", true},
+		{"a", true},
+		{"a/b.c", true},
+		{"", false},
+		{"/etc/passwd", false},
+		{"..", false},
+		{"../x", false},
+		{"a/../../x", false},
+		{"a/../b", false},
+		{"./a", false},
+		{"a//b", false},
+		{"a/", false},
+		{"a\\..\\b", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			err := checkKey(tt.key)
+			if tt.valid && err != nil {
+				t.Errorf("got error %v; want nil", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidKey) {
+				t.Errorf("got error %v; want ErrInvalidKey", err)
+			}
+		})
+	}
+}
+
+func TestStores(t *testing.T) {
+	disk, err := NewDisk(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stores := []struct {
+		name  string
+		store Store
+	}{
+		{"Disk", disk},
+		{"Memory", NewMemory()},
+	}
+
+	for _, tt := range stores {
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.store
+
+			err := s.Put("avatars/1/2-64.png", []byte("image"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			f, _, err := s.Open("avatars/1/2-64.png")
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := io.ReadAll(f)
+			f.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != "image" {
+				t.Errorf("got %q; want %q", data, "image")
+			}
+
+			err = s.Delete("avatars/1/2-64.png")
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, _, err = s.Open("avatars/1/2-64.png")
+			if !errors.Is(err, ErrNotFound) {
+				t.Errorf("after Delete: got error %v; want ErrNotFound", err)
+			}
+
+			err = s.Delete("avatars/1/2-64.png")
+			if err != nil {
+				t.Errorf("deleting again: got error %v; want nil", err)
+			}
+
+			err = s.Put("../escaped", []byte("image"))
+			if !errors.Is(err, ErrInvalidKey) {
+				t.Errorf("Put outside the store: got error %v; want ErrInvalidKey", err)
+			}
+			_, _, err = s.Open("../escaped")
+			if !errors.Is(err, ErrInvalidKey) {
+				t.Errorf("Open outside the store: got error %v; want ErrInvalidKey", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+// Package listener opens the server's listening socket, or takes over one
+// that was passed down, so that restarting the server doesn't refuse any
+// connections. A socket can come from systemd socket activation, or from a
+// previous process which started a new one with Handoff: the new process
+// inherits the socket, calls Ready once it's serving, and the old one then
+// gets SIGTERM and drains its requests.
+//
+// Under systemd, handing over changes the service's main process, so the
+// unit needs Type=notify and NotifyAccess=all for systemd to follow it.
+//
+// 打开服务器的监听 socket，或者接管传下来的 socket，这样重启服务器时不会拒绝任何连接。
+// socket 可以来自 systemd 的 socket activation，也可以来自通过 Handoff 启动新进程的旧进程：
+// 新进程继承 socket，开始服务后调用 Ready，然后旧进程会收到 SIGTERM 并处理完剩下的请求。
+//
+// 在 systemd 下，移交会改变服务的主进程，所以 unit 需要设置 Type=notify 和 NotifyAccess=all，
+// systemd 才能跟踪新的进程
+package listener
+
+import "net"
+
+// Listen returns the inherited listener if there is one, and otherwise
+// listens on addr. inherited reports which it was.
+// 如果有继承的监听器就返回它，否则监听 addr。inherited 表示是哪一种
+func Listen(addr string) (ln net.Listener, inherited bool, err error) {
+	ln, err = inherit()
+	if err != nil || ln != nil {
+		return ln, ln != nil, err
+	}
+
+	ln, err = net.Listen("tcp", addr)
+	return ln, false, err
+}
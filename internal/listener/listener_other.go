@@ -0,0 +1,30 @@
+//go:build !unix
+
+package listener
+
+import (
+	"errors"
+	"net"
+	"os"
+)
+
+// HandoffSignal is nil because handing over a listener isn't supported on
+// this platform.
+// 这个平台不支持移交监听器，所以是 nil
+var HandoffSignal os.Signal
+
+func inherit() (net.Listener, error) {
+	return nil, nil
+}
+
+// Handoff isn't supported on this platform.
+// 这个平台不支持移交
+func Handoff(ln net.Listener) (*os.Process, error) {
+	return nil, errors.New("listener: handing over isn't supported on this platform")
+}
+
+// Ready does nothing on this platform.
+// 在这个平台上什么都不做
+func Ready() error {
+	return nil
+}
@@ -0,0 +1,149 @@
+//go:build unix
+
+package listener
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// HandoffSignal is the signal which asks the server to hand its listener
+// over to a new process.
+// 要求服务器把监听器移交给新进程的信号
+var HandoffSignal os.Signal = syscall.SIGUSR2
+
+const (
+	// firstFD is where passed down sockets start, after stdin, stdout and
+	// stderr.
+	// 传下来的 socket 从这个文件描述符开始，排在 stdin、stdout 和 stderr 之后
+	firstFD = 3
+
+	// handoffPIDEnv holds the process ID of the process handing over.
+	// 保存移交监听器的进程 ID
+	handoffPIDEnv = "HANDOFF_PID"
+)
+
+// parentPID is the process which handed over its listener, if any, to be
+// told to shut down by Ready.
+// 移交了监听器的进程（如果有的话），Ready 会通知它退出
+var parentPID int
+
+func inherit() (net.Listener, error) {
+	switch {
+	case os.Getenv("LISTEN_PID") == strconv.Itoa(os.Getpid()):
+		n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("listener: bad LISTEN_FDS %q", os.Getenv("LISTEN_FDS"))
+		}
+		if n > 1 {
+			return nil, fmt.Errorf("listener: systemd passed %d sockets, but only one is used", n)
+		}
+
+	case os.Getenv(handoffPIDEnv) != "" && os.Getenv(handoffPIDEnv) == strconv.Itoa(os.Getppid()):
+		parentPID = os.Getppid()
+
+	default:
+		return nil, nil
+	}
+
+	// Don't pass the variables on to anything we start.
+	// 不把这些变量传给我们启动的其他进程
+	for _, k := range []string{"LISTEN_PID", "LISTEN_FDS", "LISTEN_FDNAMES", handoffPIDEnv} {
+		os.Unsetenv(k)
+	}
+
+	f := os.NewFile(firstFD, "listener")
+	defer f.Close()
+
+	ln, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("listener: inherited file descriptor %d: %w", firstFD, err)
+	}
+	return ln, nil
+}
+
+// Handoff starts the server's executable again, which may have been
+// replaced by a new version, with the same arguments and ln as its
+// inherited listener. The new process sends this one SIGTERM once it's
+// ready; until then this one carries on serving, and if the new process
+// fails it simply carries on.
+// 用相同的参数再次启动服务器的可执行文件（可能已经被替换成新版本），把 ln 作为新进程继承的监听器。
+// 新进程准备好之后会向这个进程发送 SIGTERM，在此之前这个进程继续服务，如果新进程失败也只是继续服务
+func Handoff(ln net.Listener) (*os.Process, error) {
+	fl, ok := ln.(interface{ File() (*os.File, error) })
+	if !ok {
+		return nil, fmt.Errorf("listener: can't hand over a %T", ln)
+	}
+
+	f, err := fl.File()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Env = append(environWithout(handoffPIDEnv), handoffPIDEnv+"="+strconv.Itoa(os.Getpid()))
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.ExtraFiles = []*os.File{f} // becomes firstFD
+
+	err = cmd.Start()
+	if err != nil {
+		return nil, err
+	}
+	return cmd.Process, nil
+}
+
+// Ready says that the server is serving. It tells the process which handed
+// over its listener, if any, to shut down, and tells systemd if it's
+// waiting for a notification.
+// 表示服务器已经开始服务。通知移交了监听器的进程（如果有的话）退出，如果 systemd 在等待通知，也通知 systemd
+func Ready() error {
+	if parentPID != 0 {
+		err := syscall.Kill(parentPID, syscall.SIGTERM)
+		if err != nil && !errors.Is(err, syscall.ESRCH) {
+			return err
+		}
+		parentPID = 0
+	}
+
+	return notify(fmt.Sprintf("MAINPID=%d\nREADY=1", os.Getpid()))
+}
+
+// notify sends state to systemd, if it's listening.
+// 如果 systemd 在监听，向它发送 state
+func notify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+func environWithout(name string) []string {
+	var env []string
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, name+"=") {
+			env = append(env, kv)
+		}
+	}
+	return env
+}
@@ -0,0 +1,76 @@
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LogMailer writes outgoing emails to a logger instead of sending them. It's
+// intended for local development, so that links in emails (like password
+// reset links) can be copied straight from the terminal.
+// LogMailer 把邮件内容写入日志而不是真正发送，方便本地开发
+type LogMailer struct {
+	Logger *log.Logger
+	Sender string
+}
+
+func NewLog(logger *log.Logger, sender string) *LogMailer {
+	return &LogMailer{Logger: logger, Sender: sender}
+}
+
+func (m *LogMailer) Send(recipient, templateFile string, data any) error {
+	msg, err := newMessage(m.Sender, recipient, templateFile, data)
+	if err != nil {
+		return err
+	}
+
+	m.Logger.Printf("email to %s: %s\n%s", msg.to, msg.subject, msg.plainBody)
+	return nil
+}
+
+// FileMailer writes each outgoing email as an .eml file in Dir, which can be
+// opened with any mail client to check the rendered HTML.
+// FileMailer 把每封邮件保存为 .eml 文件
+type FileMailer struct {
+	Dir    string
+	Sender string
+}
+
+func NewFile(dir, sender string) (*FileMailer, error) {
+	err := os.MkdirAll(dir, 0o750)
+	if err != nil {
+		return nil, err
+	}
+	return &FileMailer{Dir: dir, Sender: sender}, nil
+}
+
+func (m *FileMailer) Send(recipient, templateFile string, data any) error {
+	msg, err := newMessage(m.Sender, recipient, templateFile, data)
+	if err != nil {
+		return err
+	}
+
+	body, err := msg.bytes()
+	if err != nil {
+		return err
+	}
+
+	// Name the file after the time and template so that the directory listing
+	// sorts in the order the emails were sent.
+	// 文件名包含时间和模板名，按发送顺序排序
+	name := fmt.Sprintf("%s-%s.eml",
+		msg.date.UTC().Format("20060102T150405.000000000"),
+		strings.TrimSuffix(templateFile, filepath.Ext(templateFile)))
+
+	return os.WriteFile(filepath.Join(m.Dir, name), body, 0o640)
+}
+
+// Compile-time checks that every transport satisfies the Mailer interface.
+var (
+	_ Mailer = (*SMTPMailer)(nil)
+	_ Mailer = (*LogMailer)(nil)
+	_ Mailer = (*FileMailer)(nil)
+)
@@ -0,0 +1,131 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"mime/multipart"
+	"net/textproto"
+	"strings"
+	"time"
+
+	tt "text/template"
+)
+
+// Use the //go:embed directive to store the contents of the ./templates
+// directory in the templateFS variable, so the email templates ship inside
+// the binary.
+// 使用 go:embed 指令把 templates 目录嵌入到二进制文件中
+//
+//go:embed "templates"
+var templateFS embed.FS
+
+// Mailer is implemented by anything which can deliver a templated email. The
+// templateFile is the name of a file in the embedded templates directory and
+// must define the "subject", "plainBody" and "htmlBody" templates.
+// Mailer 接口，不同的实现负责把邮件真正发送出去（SMTP、日志、文件等）
+type Mailer interface {
+	Send(recipient, templateFile string, data any) error
+}
+
+// message holds a rendered email ready to be handed to a transport.
+type message struct {
+	from      string
+	to        string
+	subject   string
+	plainBody string
+	htmlBody  string
+	date      time.Time
+}
+
+// newMessage executes the named template with the dynamic data and returns
+// the rendered message.
+// 渲染模板，返回一封完整的邮件
+func newMessage(sender, recipient, templateFile string, data any) (*message, error) {
+	// The subject and plain-text body are parsed with text/template so that
+	// nothing gets HTML-escaped, the HTML body uses html/template.
+	// subject 和纯文本内容使用 text/template，HTML 内容使用 html/template 保证转义
+	tmpl, err := tt.New("email").ParseFS(templateFS, "templates/"+templateFile)
+	if err != nil {
+		return nil, err
+	}
+
+	subject := new(bytes.Buffer)
+	err = tmpl.ExecuteTemplate(subject, "subject", data)
+	if err != nil {
+		return nil, err
+	}
+
+	plainBody := new(bytes.Buffer)
+	err = tmpl.ExecuteTemplate(plainBody, "plainBody", data)
+	if err != nil {
+		return nil, err
+	}
+
+	htmlTmpl, err := template.New("email").ParseFS(templateFS, "templates/"+templateFile)
+	if err != nil {
+		return nil, err
+	}
+
+	htmlBody := new(bytes.Buffer)
+	err = htmlTmpl.ExecuteTemplate(htmlBody, "htmlBody", data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &message{
+		from:      sender,
+		to:        recipient,
+		subject:   strings.TrimSpace(subject.String()),
+		plainBody: plainBody.String(),
+		htmlBody:  htmlBody.String(),
+		date:      time.Now(),
+	}, nil
+}
+
+// bytes encodes the message as a multipart/alternative MIME document, which
+// is what both the SMTP and file transports send or store.
+// 把邮件编码成 multipart/alternative 格式
+func (msg *message) bytes() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	mw := multipart.NewWriter(buf)
+
+	fmt.Fprintf(buf, "From: %s\r\n", msg.from)
+	fmt.Fprintf(buf, "To: %s\r\n", msg.to)
+	fmt.Fprintf(buf, "Subject: %s\r\n", msg.subject)
+	fmt.Fprintf(buf, "Date: %s\r\n", msg.date.Format(time.RFC1123Z))
+	fmt.Fprintf(buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(buf, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", mw.Boundary())
+
+	parts := []struct {
+		contentType string
+		body        string
+	}{
+		{"text/plain; charset=UTF-8", msg.plainBody},
+		{"text/html; charset=UTF-8", msg.htmlBody},
+	}
+
+	for _, p := range parts {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", p.contentType)
+		header.Set("Content-Transfer-Encoding", "8bit")
+
+		pw, err := mw.CreatePart(header)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = pw.Write([]byte(strings.ReplaceAll(p.body, "\n", "\r\n")))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := mw.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,116 @@
+package mailer
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+func TestNewMessage(t *testing.T) {
+	tests := []struct {
+		template string
+		data     map[string]any
+		subject  string
+		link     string
+	}{
+		{
+			template: "password_reset.tmpl",
+			data:     map[string]any{"Name": "Alice <3", "ResetURL": "https://example.com/user/password/reset/ABC", "TTL": "1 hour"},
+			subject:  "Reset your Snippetbox password",
+			link:     "https://example.com/user/password/reset/ABC",
+		},
+		{
+			template: "user_verification.tmpl",
+			data:     map[string]any{"Name": "Alice <3", "VerifyURL": "https://example.com/user/verify/ABC", "TTL": "24 hours"},
+			link:     "https://example.com/user/verify/ABC",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			msg, err := newMessage("Snippetbox <no-reply@example.com>", "alice@example.com", tt.template, tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if msg.subject == "" || strings.Contains(msg.subject, "\n") {
+				t.Errorf("got subject %q; want a single line", msg.subject)
+			}
+			if tt.subject != "" && msg.subject != tt.subject {
+				t.Errorf("got subject %q; want %q", msg.subject, tt.subject)
+			}
+			if !strings.Contains(msg.plainBody, tt.link) || !strings.Contains(msg.htmlBody, tt.link) {
+				t.Errorf("the link %q is missing from a body", tt.link)
+			}
+
+			// The plain text body isn't escaped, the HTML body is.
+			if !strings.Contains(msg.plainBody, "Alice <3") {
+				t.Errorf("plain body doesn't have the name as it is:\n%s", msg.plainBody)
+			}
+			if !strings.Contains(msg.htmlBody, "Alice &lt;3") {
+				t.Errorf("HTML body doesn't have the name escaped:\n%s", msg.htmlBody)
+			}
+		})
+	}
+}
+
+func TestNewMessageMissingTemplate(t *testing.T) {
+	_, err := newMessage("no-reply@example.com", "alice@example.com", "missing.tmpl", nil)
+	if err == nil {
+		t.Error("got no error; want one")
+	}
+}
+
+func TestMessageBytes(t *testing.T) {
+	msg, err := newMessage("no-reply@example.com", "alice@example.com", "password_reset.tmpl",
+		map[string]any{"Name": "Alice", "ResetURL": "https://example.com/reset", "TTL": "1 hour"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := msg.bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := mail.ReadMessage(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := parsed.Header.Get("Subject"); got != msg.subject {
+		t.Errorf("got subject %q; want %q", got, msg.subject)
+	}
+	if got := parsed.Header.Get("To"); got != "alice@example.com" {
+		t.Errorf("got To %q; want alice@example.com", got)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mediaType != "multipart/alternative" {
+		t.Fatalf("got %s; want multipart/alternative", mediaType)
+	}
+
+	var types []string
+	mr := multipart.NewReader(parsed.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		types = append(types, part.Header.Get("Content-Type"))
+	}
+
+	want := []string{"text/plain; charset=UTF-8", "text/html; charset=UTF-8"}
+	if strings.Join(types, ",") != strings.Join(want, ",") {
+		t.Errorf("got parts %q; want %q", types, want)
+	}
+}
@@ -0,0 +1,64 @@
+package mailer
+
+import (
+	"fmt"
+	"net/smtp"
+	"time"
+)
+
+// SMTPMailer delivers email through an SMTP server. net/smtp upgrades the
+// connection with STARTTLS automatically when the server advertises it.
+// SMTPMailer 通过 SMTP 服务器发送邮件
+type SMTPMailer struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	Sender   string
+}
+
+func NewSMTP(host string, port int, username, password, sender string) *SMTPMailer {
+	return &SMTPMailer{
+		Host:     host,
+		Port:     port,
+		Username: username,
+		Password: password,
+		Sender:   sender,
+	}
+}
+
+func (m *SMTPMailer) Send(recipient, templateFile string, data any) error {
+	msg, err := newMessage(m.Sender, recipient, templateFile, data)
+	if err != nil {
+		return err
+	}
+
+	body, err := msg.bytes()
+	if err != nil {
+		return err
+	}
+
+	// Only authenticate if credentials were configured, a local relay often
+	// doesn't need them.
+	// 只有配置了用户名时才进行认证，本地的邮件中继通常不需要
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	addr := fmt.Sprintf("%s:%d", m.Host, m.Port)
+
+	// Try sending the email up to three times before aborting and returning
+	// the final error. We sleep for 500 milliseconds between each attempt.
+	// 最多尝试发送三次，每次间隔 500 毫秒
+	for i := 1; i <= 3; i++ {
+		err = smtp.SendMail(addr, auth, m.Sender, []string{recipient}, body)
+		if err == nil {
+			return nil
+		}
+
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	return err
+}
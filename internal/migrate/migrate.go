@@ -0,0 +1,581 @@
+// Package migrate keeps the database schema up to date. The migrations are
+// SQL scripts embedded in the binary, named like 0001_create_snippets.up.sql
+// with a matching .down.sql script to roll each one back, in a directory for
+// each dialect. Which ones have been applied is recorded in the
+// schema_migrations table.
+//
+// A dialect added after the first migrations were written starts from a
+// baseline: its first migration has a version above 1 and creates the whole
+// schema as it was at that version, and later migrations share their
+// versions with the other dialects. Down won't roll back a baseline, because
+// that would drop every table.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"snippetbox.ab.net/internal/database"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//go:embed migrations/*/*.sql
+var embedded embed.FS
+
+var (
+	ErrLocked   = errors.New("migrate: another migration is in progress")
+	ErrDirty    = errors.New("migrate: a migration failed part way through")
+	ErrUnknown  = errors.New("migrate: the database has a migration this version doesn't know about")
+	ErrBaseline = errors.New("migrate: can't roll back the baseline migration, which would drop the whole schema")
+)
+
+// Migration is one version of the schema.
+// 数据库结构的一个版本
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status describes a migration and whether it has been applied. Applied is
+// the zero time for pending migrations.
+// 迁移及其应用状态，未应用的迁移 Applied 为零值
+type Status struct {
+	Version int
+	Name    string
+	Applied time.Time
+	Dirty   bool
+}
+
+func (s Status) IsApplied() bool {
+	return !s.Applied.IsZero()
+}
+
+var filenameRX = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Load reads the migrations in a directory of fsys, sorted by version. Every
+// migration needs both an up and a down script.
+// 读取 fsys 目录中的迁移并按版本排序，每个迁移都需要 up 和 down 两个脚本
+func Load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+
+	for _, entry := range entries {
+		matches := filenameRX.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			return nil, fmt.Errorf("migrate: unexpected file %q", entry.Name())
+		}
+
+		version, err := strconv.Atoi(matches[1])
+		if err != nil || version < 1 {
+			return nil, fmt.Errorf("migrate: bad version in %q", entry.Name())
+		}
+
+		data, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: matches[2]}
+			byVersion[version] = m
+		}
+		if m.Name != matches[2] {
+			return nil, fmt.Errorf("migrate: version %d has two names, %q and %q", version, m.Name, matches[2])
+		}
+
+		if matches[3] == "up" {
+			m.Up = string(data)
+		} else {
+			m.Down = string(data)
+		}
+	}
+
+	migrations := []Migration{}
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migrate: version %d needs both an up and a down script", m.Version)
+		}
+		migrations = append(migrations, *m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Migrator applies and rolls back migrations. Only one Migrator can change
+// a database at a time, across all processes, so instances starting at the
+// same time don't trip over each other. The others wait for up to
+// LockTimeout and then give up with ErrLocked.
+// 应用和回滚迁移。所有进程中同时只能有一个 Migrator 修改同一个数据库，这样同时启动的实例不会互相干扰。
+// 其他 Migrator 最多等待 LockTimeout，之后返回 ErrLocked
+type Migrator struct {
+	DB          *database.DB
+	Migrations  []Migration
+	LockTimeout time.Duration
+}
+
+// New returns a Migrator for the migrations embedded in this package for
+// the database's dialect.
+// 返回使用本包中对应数据库方言的内嵌迁移的 Migrator
+func New(db *database.DB) (*Migrator, error) {
+	migrations, err := Load(embedded, path.Join("migrations", db.Dialect))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{DB: db, Migrations: migrations, LockTimeout: 30 * time.Second}, nil
+}
+
+// Latest returns the newest version known to the Migrator.
+// 返回 Migrator 已知的最新版本
+func (m *Migrator) Latest() int {
+	if len(m.Migrations) == 0 {
+		return 0
+	}
+	return m.Migrations[len(m.Migrations)-1].Version
+}
+
+// record is a row of schema_migrations.
+type record struct {
+	name    string
+	applied time.Time
+	dirty   bool
+}
+
+// Status lists every migration, known or applied, in version order. It
+// doesn't need the lock or any privileges beyond SELECT.
+// 按版本顺序列出所有已知或已应用的迁移，不需要加锁，也只需要 SELECT 权限
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	stmt := `SELECT COUNT(*) FROM information_schema.tables
+    WHERE table_schema = DATABASE() AND table_name = 'schema_migrations'`
+	switch m.DB.Dialect {
+	case database.Postgres:
+		stmt = `SELECT COUNT(*) FROM information_schema.tables
+    WHERE table_schema = current_schema() AND table_name = 'schema_migrations'`
+	case database.SQLite:
+		stmt = `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'`
+	}
+
+	var n int
+	err := m.DB.QueryRowContext(ctx, stmt).Scan(&n)
+	if err != nil {
+		return nil, err
+	}
+
+	applied := map[int]record{}
+	if n > 0 {
+		applied, err = readApplied(ctx, m.DB)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var statuses []Status
+	for _, mig := range m.Migrations {
+		s := Status{Version: mig.Version, Name: mig.Name}
+		if r, ok := applied[mig.Version]; ok {
+			s.Applied = r.applied
+			s.Dirty = r.dirty
+			delete(applied, mig.Version)
+		}
+		statuses = append(statuses, s)
+	}
+
+	// Anything left was applied by a newer version of the application.
+	// 剩下的是更新版本的程序应用的迁移
+	for version, r := range applied {
+		statuses = append(statuses, Status{Version: version, Name: r.name, Applied: r.applied, Dirty: r.dirty})
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+
+	return statuses, nil
+}
+
+// Pending returns how many known migrations haven't been applied.
+// 返回还没有应用的已知迁移数量
+func (m *Migrator) Pending(ctx context.Context) (int, error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, s := range statuses {
+		if !s.IsApplied() {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// Up applies every pending migration up to and including version target,
+// or all of them if target is 0, and returns the ones it applied.
+// 应用版本不超过 target 的所有待执行迁移（target 为 0 时应用全部），返回应用了的迁移
+func (m *Migrator) Up(ctx context.Context, target int) ([]Migration, error) {
+	var done []Migration
+
+	err := m.locked(ctx, func(conn *database.Conn, applied map[int]record) error {
+		for _, mig := range m.Migrations {
+			if target != 0 && mig.Version > target {
+				break
+			}
+			if _, ok := applied[mig.Version]; ok {
+				continue
+			}
+
+			err := apply(ctx, conn, mig)
+			if err != nil {
+				return err
+			}
+			done = append(done, mig)
+		}
+		return nil
+	})
+
+	return done, err
+}
+
+// Down rolls back the newest steps applied migrations and returns them. If
+// that would include a baseline it returns ErrBaseline without rolling back
+// anything.
+// 回滚最新的 steps 个已应用的迁移，并返回它们。如果其中包括基线迁移，
+// 则不回滚任何迁移并返回 ErrBaseline
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+
+	err := m.locked(ctx, func(conn *database.Conn, applied map[int]record) error {
+		versions := make([]int, 0, len(applied))
+		for version := range applied {
+			versions = append(versions, version)
+		}
+		sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+
+		if steps < len(versions) {
+			versions = versions[:steps]
+		}
+
+		if baseline := m.baseline(); len(versions) > 0 && versions[len(versions)-1] <= baseline {
+			return fmt.Errorf("%w (%04d)", ErrBaseline, baseline)
+		}
+
+		for _, version := range versions {
+			mig, ok := m.find(version)
+			if !ok {
+				return fmt.Errorf("%w: version %d", ErrUnknown, version)
+			}
+
+			err := rollBack(ctx, conn, mig)
+			if err != nil {
+				return err
+			}
+			done = append(done, mig)
+		}
+		return nil
+	})
+
+	return done, err
+}
+
+// Force records the schema as being at exactly version, without running any
+// scripts: known migrations up to version are marked as applied and
+// anything newer is forgotten. It's for recovering from a failed migration
+// once the schema has been fixed by hand, and for adopting a database which
+// was set up before migrations existed.
+// 不执行任何脚本，直接把数据库结构记录为 version 版本：不超过 version 的已知迁移标记为已应用，更新的记录被删除。
+// 用于在手动修复失败的迁移之后恢复，以及接管在迁移功能之前就已经建好的数据库
+func (m *Migrator) Force(ctx context.Context, version int) error {
+	return m.lockedAllowDirty(ctx, func(conn *database.Conn, applied map[int]record) error {
+		_, err := conn.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version > ? OR dirty", version)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range m.Migrations {
+			if mig.Version > version {
+				break
+			}
+			// Versions which were applied cleanly are still there.
+			// 干净地应用过的版本仍然保留着
+			if r, ok := applied[mig.Version]; ok && !r.dirty {
+				continue
+			}
+			_, err = conn.ExecContext(ctx, `INSERT INTO schema_migrations (version, name, dirty, applied)
+    VALUES(?, ?, FALSE, ?)`, mig.Version, mig.Name, time.Now().UTC())
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// baseline returns the version of the baseline migration, or 0 if there
+// isn't one.
+// 返回基线迁移的版本，没有基线迁移时返回 0
+func (m *Migrator) baseline() int {
+	if len(m.Migrations) == 0 || m.Migrations[0].Version == 1 {
+		return 0
+	}
+	return m.Migrations[0].Version
+}
+
+func (m *Migrator) find(version int) (Migration, bool) {
+	for _, mig := range m.Migrations {
+		if mig.Version == version {
+			return mig, true
+		}
+	}
+	return Migration{}, false
+}
+
+// locked runs fn holding the migration lock, on the connection which holds
+// it, after making sure schema_migrations exists and that no earlier
+// migration was left half done.
+// 持有迁移锁运行 fn（使用持有锁的连接），运行前确保 schema_migrations 表存在，并且之前没有执行到一半的迁移
+func (m *Migrator) locked(ctx context.Context, fn func(*database.Conn, map[int]record) error) error {
+	return m.lockedAllowDirty(ctx, func(conn *database.Conn, applied map[int]record) error {
+		for version, r := range applied {
+			if r.dirty {
+				return fmt.Errorf("%w: version %d; fix the schema by hand, then force the version it's at", ErrDirty, version)
+			}
+		}
+		return fn(conn, applied)
+	})
+}
+
+func (m *Migrator) lockedAllowDirty(ctx context.Context, fn func(*database.Conn, map[int]record) error) (err error) {
+	// The locks belong to a connection, so everything has to happen on the
+	// same one.
+	// 锁属于单个连接，所以所有操作都必须在同一个连接上进行
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	unlock, err := m.lock(ctx, conn)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if unlockErr := unlock(err); err == nil {
+			err = unlockErr
+		}
+	}()
+
+	// MySQL's TIMESTAMP columns are converted to and from the session's
+	// time zone, so it gets a DATETIME instead; PostgreSQL and SQLite don't
+	// have those.
+	// MySQL 的 TIMESTAMP 列会按照会话的时区转换，所以使用 DATETIME；PostgreSQL 和 SQLite 没有 DATETIME
+	appliedType := "TIMESTAMP"
+	if m.DB.Dialect == database.MySQL {
+		appliedType = "DATETIME"
+	}
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version BIGINT NOT NULL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    dirty BOOLEAN NOT NULL,
+    applied `+appliedType+` NOT NULL
+)`)
+	if err != nil {
+		return err
+	}
+
+	applied, err := readApplied(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	return fn(conn, applied)
+}
+
+// lock takes the migration lock on conn, waiting for up to LockTimeout. The
+// function it returns releases the lock, and is passed the error the
+// migration ended with.
+// 在 conn 上获取迁移锁，最多等待 LockTimeout。返回的函数用于释放锁，参数是迁移结束时的错误
+func (m *Migrator) lock(ctx context.Context, conn *database.Conn) (func(error) error, error) {
+	switch m.DB.Dialect {
+	case database.Postgres:
+		// Advisory locks are per database, and the key is hashed from the
+		// same name MySQL uses.
+		// advisory lock 属于单个数据库，key 由和 MySQL 相同的锁名哈希得到
+		const key = "hashtext(current_database() || '.schema_migrations')"
+
+		err := m.poll(ctx, func() (bool, error) {
+			var got bool
+			err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock("+key+")").Scan(&got)
+			return got, err
+		})
+		if err != nil {
+			return nil, err
+		}
+		return func(error) error {
+			_, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock("+key+")")
+			return err
+		}, nil
+
+	case database.SQLite:
+		// SQLite's write lock covers the whole file. Holding it means that
+		// the migrations run in a single transaction, which SQLite can
+		// roll back, DDL and all.
+		// SQLite 的写锁覆盖整个文件。持有写锁意味着迁移在同一个事务中运行，SQLite 可以连同 DDL 一起回滚
+		err := m.poll(ctx, func() (bool, error) {
+			_, err := conn.ExecContext(ctx, "BEGIN IMMEDIATE")
+			if database.IsBusy(err) {
+				return false, nil
+			}
+			return err == nil, err
+		})
+		if err != nil {
+			return nil, err
+		}
+		return func(err error) error {
+			if err != nil {
+				_, err = conn.ExecContext(context.Background(), "ROLLBACK")
+				return err
+			}
+			_, err = conn.ExecContext(context.Background(), "COMMIT")
+			return err
+		}, nil
+	}
+
+	// The lock name includes the database name, so that databases sharing
+	// a server don't block each other.
+	// 锁名包含数据库名，这样同一个服务器上的不同数据库不会互相阻塞
+	var got sql.NullInt64
+	err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(CONCAT(DATABASE(), '.schema_migrations'), ?)",
+		int(m.LockTimeout/time.Second)).Scan(&got)
+	if err != nil {
+		return nil, err
+	}
+	if got.Int64 != 1 {
+		return nil, ErrLocked
+	}
+	return func(error) error {
+		_, err := conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(CONCAT(DATABASE(), '.schema_migrations'))")
+		return err
+	}, nil
+}
+
+// poll calls try until it gets the lock, giving up with ErrLocked after
+// LockTimeout.
+// 反复调用 try 直到获得锁，超过 LockTimeout 后返回 ErrLocked
+func (m *Migrator) poll(ctx context.Context, try func() (bool, error)) error {
+	deadline := time.Now().Add(m.LockTimeout)
+
+	for {
+		got, err := try()
+		if err != nil || got {
+			return err
+		}
+		if time.Now().After(deadline) {
+			return ErrLocked
+		}
+
+		select {
+		case <-time.After(250 * time.Millisecond):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// queryer is satisfied by both *database.DB and *database.Conn.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+func readApplied(ctx context.Context, q queryer) (map[int]record, error) {
+	rows, err := q.QueryContext(ctx, "SELECT version, name, dirty, applied FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]record{}
+	for rows.Next() {
+		var version int
+		var r record
+		err = rows.Scan(&version, &r.name, &r.dirty, &r.applied)
+		if err != nil {
+			return nil, err
+		}
+		applied[version] = r
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return applied, nil
+}
+
+// apply runs a migration's up script. MySQL commits DDL statements
+// straight away, so a transaction wouldn't help. Instead the migration is
+// marked dirty while it runs, and a failure leaves it marked for someone to
+// look at.
+// 执行迁移的 up 脚本。MySQL 的 DDL 语句会立即提交，事务没有用，所以执行期间把迁移标记为 dirty，
+// 失败时保留标记，等待人工处理
+func apply(ctx context.Context, conn *database.Conn, mig Migration) error {
+	_, err := conn.ExecContext(ctx, `INSERT INTO schema_migrations (version, name, dirty, applied)
+    VALUES(?, ?, TRUE, ?)`, mig.Version, mig.Name, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	err = run(ctx, conn, mig, mig.Up)
+	if err != nil {
+		return err
+	}
+
+	_, err = conn.ExecContext(ctx, "UPDATE schema_migrations SET dirty = FALSE WHERE version = ?", mig.Version)
+	return err
+}
+
+// rollBack runs a migration's down script, the same way.
+// 用同样的方式执行迁移的 down 脚本
+func rollBack(ctx context.Context, conn *database.Conn, mig Migration) error {
+	_, err := conn.ExecContext(ctx, "UPDATE schema_migrations SET dirty = TRUE WHERE version = ?", mig.Version)
+	if err != nil {
+		return err
+	}
+
+	err = run(ctx, conn, mig, mig.Down)
+	if err != nil {
+		return err
+	}
+
+	_, err = conn.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = ?", mig.Version)
+	return err
+}
+
+func run(ctx context.Context, conn *database.Conn, mig Migration, script string) error {
+	for i, stmt := range splitStatements(script) {
+		_, err := conn.ExecContext(ctx, stmt)
+		if err != nil {
+			return fmt.Errorf("migrate: %04d_%s statement %d: %w", mig.Version, mig.Name, i+1, err)
+		}
+	}
+	return nil
+}
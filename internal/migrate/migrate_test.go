@@ -0,0 +1,162 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"snippetbox.ab.net/internal/database"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	file := func(s string) *fstest.MapFile { return &fstest.MapFile{Data: []byte(s)} }
+
+	tests := []struct {
+		name     string
+		files    fstest.MapFS
+		versions []int
+		err      bool
+	}{
+		{
+			name: "Sorted by version",
+			files: fstest.MapFS{
+				"m/0010_b.up.sql":   file("up b"),
+				"m/0010_b.down.sql": file("down b"),
+				"m/0002_a.up.sql":   file("up a"),
+				"m/0002_a.down.sql": file("down a"),
+			},
+			versions: []int{2, 10},
+		},
+		{
+			name:  "Missing down",
+			files: fstest.MapFS{"m/0001_a.up.sql": file("up")},
+			err:   true,
+		},
+		{
+			name: "Empty script",
+			files: fstest.MapFS{
+				"m/0001_a.up.sql":   file("up"),
+				"m/0001_a.down.sql": file(""),
+			},
+			err: true,
+		},
+		{
+			name: "Two names",
+			files: fstest.MapFS{
+				"m/0001_a.up.sql":   file("up"),
+				"m/0001_b.down.sql": file("down"),
+			},
+			err: true,
+		},
+		{
+			name:  "Unexpected file",
+			files: fstest.MapFS{"m/README": file("hello")},
+			err:   true,
+		},
+		{
+			name: "Version zero",
+			files: fstest.MapFS{
+				"m/0000_a.up.sql":   file("up"),
+				"m/0000_a.down.sql": file("down"),
+			},
+			err: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrations, err := Load(tt.files, "m")
+			if tt.err {
+				if err == nil {
+					t.Fatal("got no error; want one")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var versions []int
+			for _, m := range migrations {
+				versions = append(versions, m.Version)
+			}
+			if !reflect.DeepEqual(versions, tt.versions) {
+				t.Errorf("got versions %v; want %v", versions, tt.versions)
+			}
+		})
+	}
+}
+
+func TestEmbedded(t *testing.T) {
+	for _, dialect := range []string{"mysql", "postgres", "sqlite"} {
+		t.Run(dialect, func(t *testing.T) {
+			migrations, err := Load(embedded, "migrations/"+dialect)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(migrations) == 0 {
+				t.Fatal("no migrations")
+			}
+		})
+	}
+}
+
+func TestDownBaseline(t *testing.T) {
+	table := func(version int, name string) Migration {
+		return Migration{
+			Version: version,
+			Name:    "create_" + name,
+			Up:      "CREATE TABLE " + name + " (id INTEGER);",
+			Down:    "DROP TABLE " + name + ";",
+		}
+	}
+
+	tests := []struct {
+		name       string
+		migrations []Migration
+		steps      int
+		rolledBack int
+		err        error
+	}{
+		{name: "After the baseline", migrations: []Migration{table(15, "a"), table(16, "b")}, steps: 1, rolledBack: 1},
+		{name: "Including the baseline", migrations: []Migration{table(15, "a"), table(16, "b")}, steps: 2, err: ErrBaseline},
+		{name: "Only the baseline", migrations: []Migration{table(15, "a")}, steps: 1, err: ErrBaseline},
+		{name: "No baseline", migrations: []Migration{table(1, "a"), table(2, "b")}, steps: 2, rolledBack: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			db, err := database.Open("sqlite::memory:")
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { db.Close() })
+
+			m := &Migrator{DB: db, Migrations: tt.migrations, LockTimeout: time.Second}
+			_, err = m.Up(ctx, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			done, err := m.Down(ctx, tt.steps)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got error %v; want %v", err, tt.err)
+			}
+			if len(done) != tt.rolledBack {
+				t.Errorf("rolled back %d migrations; want %d", len(done), tt.rolledBack)
+			}
+
+			pending, err := m.Pending(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if pending != tt.rolledBack {
+				t.Errorf("got %d pending migrations; want %d", pending, tt.rolledBack)
+			}
+		})
+	}
+}
@@ -0,0 +1,9 @@
+-- scs mysqlstore 使用的 session 表
+-- The session data table used by the scs mysqlstore.
+CREATE TABLE sessions (
+    token CHAR(43) PRIMARY KEY,
+    data BLOB NOT NULL,
+    expiry TIMESTAMP(6) NOT NULL
+);
+
+CREATE INDEX sessions_expiry_idx ON sessions (expiry);
@@ -0,0 +1,13 @@
+-- 保存密码重置等用途的一次性 token，只保存 token 的哈希值
+-- Single-use tokens (e.g. for password resets). Only a SHA-256 hash of the
+-- token is stored.
+CREATE TABLE tokens (
+    hash CHAR(64) NOT NULL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    scope VARCHAR(32) NOT NULL,
+    created DATETIME NOT NULL,
+    expiry DATETIME NOT NULL,
+    CONSTRAINT tokens_fk_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_tokens_user_scope ON tokens(user_id, scope);
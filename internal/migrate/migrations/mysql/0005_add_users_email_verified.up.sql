@@ -0,0 +1,5 @@
+-- 记录用户是否已经验证过邮箱，已有的用户视为已验证
+-- Track whether a user has verified their email address. Existing accounts
+-- are treated as verified.
+ALTER TABLE users ADD COLUMN email_verified BOOLEAN NOT NULL DEFAULT FALSE;
+UPDATE users SET email_verified = TRUE;
@@ -0,0 +1,17 @@
+-- 两步验证：保存 TOTP 密钥和最后一次使用的时间步长（防止验证码重放）
+-- Two-factor authentication: the TOTP secret, and the last time step a code
+-- was accepted for (so codes can't be replayed).
+ALTER TABLE users ADD COLUMN totp_secret VARCHAR(64) NULL;
+ALTER TABLE users ADD COLUMN totp_last_step BIGINT NOT NULL DEFAULT 0;
+
+-- 两步验证的一次性恢复码，只保存哈希值
+-- One-time two-factor recovery codes. Only a SHA-256 hash is stored.
+CREATE TABLE recovery_codes (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NOT NULL,
+    hashed_code CHAR(64) NOT NULL,
+    created DATETIME NOT NULL,
+    CONSTRAINT recovery_codes_fk_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_recovery_codes_user ON recovery_codes(user_id);
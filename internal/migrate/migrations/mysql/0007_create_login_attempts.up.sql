@@ -0,0 +1,13 @@
+-- 记录每次登录尝试，用于限制密码猜测以及供管理员查看
+-- Every login attempt, used to throttle password guessing and for admins to
+-- review.
+CREATE TABLE login_attempts (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    email VARCHAR(255) NOT NULL,
+    ip VARCHAR(45) NOT NULL,
+    result VARCHAR(16) NOT NULL,
+    created DATETIME NOT NULL
+);
+
+CREATE INDEX idx_login_attempts_email ON login_attempts(email, created);
+CREATE INDEX idx_login_attempts_ip ON login_attempts(ip, created);
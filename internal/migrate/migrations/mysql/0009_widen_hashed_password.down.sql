@@ -0,0 +1,3 @@
+-- 如果已经有 Argon2id 哈希，这一步会失败，需要先让这些用户重置密码
+-- This fails if any Argon2id hashes have been stored, since they don't fit.
+ALTER TABLE users MODIFY hashed_password CHAR(60) NOT NULL;
@@ -0,0 +1,3 @@
+-- 加宽 hashed_password 列，以便保存 Argon2id 等更长的哈希
+-- Widen hashed_password so it can hold longer hashes, such as Argon2id.
+ALTER TABLE users MODIFY hashed_password VARCHAR(255) NOT NULL;
@@ -0,0 +1,4 @@
+ALTER TABLE snippets DROP FOREIGN KEY snippets_fk_user;
+ALTER TABLE snippets DROP COLUMN user_id;
+ALTER TABLE users DROP COLUMN disabled;
+ALTER TABLE users DROP COLUMN role;
@@ -0,0 +1,11 @@
+-- 用户角色和账号禁用。第一个管理员需要手动指定：
+-- Roles and disabled accounts. The first administrator has to be appointed
+-- by hand:
+--   UPDATE users SET role = 'admin' WHERE email = 'you@example.com';
+ALTER TABLE users ADD COLUMN role VARCHAR(20) NOT NULL DEFAULT 'user';
+ALTER TABLE users ADD COLUMN disabled BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- snippet 的作者，已有的 snippet 没有作者
+-- The author of each snippet. Existing snippets don't have one.
+ALTER TABLE snippets ADD COLUMN user_id INTEGER NULL;
+ALTER TABLE snippets ADD CONSTRAINT snippets_fk_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE SET NULL;
@@ -0,0 +1,16 @@
+-- 注册邀请，只保存邀请码的哈希值
+-- Signup invitations. Only a SHA-256 hash of each code is stored.
+CREATE TABLE invitations (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    hash CHAR(64) NOT NULL,
+    created_by INTEGER NOT NULL,
+    created DATETIME NOT NULL,
+    expiry DATETIME NOT NULL,
+    used_by INTEGER NULL,
+    used DATETIME NULL,
+    CONSTRAINT invitations_uc_hash UNIQUE (hash),
+    CONSTRAINT invitations_fk_created_by FOREIGN KEY (created_by) REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT invitations_fk_used_by FOREIGN KEY (used_by) REFERENCES users (id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_invitations_created_by ON invitations(created_by);
@@ -0,0 +1,5 @@
+-- 用户的 handle，用于登录和个人主页的 URL，已有用户可以之后再选择
+-- Each user's handle, used to log in and in profile URLs. Existing users can
+-- pick one later.
+ALTER TABLE users ADD COLUMN handle VARCHAR(30) NULL;
+ALTER TABLE users ADD CONSTRAINT users_uc_handle UNIQUE (handle);
@@ -0,0 +1,4 @@
+-- 头像版本号，每次上传都会改变，0 表示没有头像
+-- The version of each user's avatar, which changes on every upload. 0 means
+-- they haven't uploaded one.
+ALTER TABLE users ADD COLUMN avatar_version BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,18 @@
+-- 审计日志，记录谁在什么时候做了什么。actor_id 不使用外键，这样账号删除之后事件仍然保留
+-- The audit log of who did what and when. actor_id deliberately has no
+-- foreign key, so that events outlive deleted accounts.
+CREATE TABLE audit_events (
+    id BIGINT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    action VARCHAR(40) NOT NULL,
+    actor_id INTEGER NULL,
+    subject VARCHAR(255) NOT NULL,
+    ip VARCHAR(45) NOT NULL,
+    user_agent VARCHAR(512) NOT NULL,
+    detail VARCHAR(255) NOT NULL,
+    created DATETIME NOT NULL
+);
+
+CREATE INDEX idx_audit_events_created ON audit_events(created);
+CREATE INDEX idx_audit_events_actor_id ON audit_events(actor_id);
+CREATE INDEX idx_audit_events_action ON audit_events(action);
+CREATE INDEX idx_audit_events_ip ON audit_events(ip);
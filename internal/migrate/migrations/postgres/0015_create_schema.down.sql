@@ -0,0 +1,13 @@
+-- -migrate down won't run this, since it drops everything; it's only here
+-- for rolling the baseline back by hand.
+-- -migrate down 不会执行这个脚本，因为它会删除所有表，只用于手动回滚基线迁移
+DROP TABLE audit_events;
+DROP TABLE invitations;
+DROP TABLE user_identities;
+DROP TABLE user_sessions;
+DROP TABLE login_attempts;
+DROP TABLE recovery_codes;
+DROP TABLE tokens;
+DROP TABLE sessions;
+DROP TABLE snippets;
+DROP TABLE users;
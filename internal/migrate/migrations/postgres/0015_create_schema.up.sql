@@ -0,0 +1,150 @@
+-- PostgreSQL 数据库的完整结构，相当于 MySQL 的 1 到 15 号迁移。之后的迁移和 MySQL 使用相同的版本号
+-- The whole schema for PostgreSQL, equivalent to MySQL's migrations 1 to 15.
+-- Later migrations use the same version numbers as MySQL's.
+CREATE TABLE users (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    handle VARCHAR(30) NULL,
+    email VARCHAR(255) NOT NULL,
+    hashed_password VARCHAR(255) NOT NULL,
+    created TIMESTAMP NOT NULL,
+    email_verified BOOLEAN NOT NULL DEFAULT FALSE,
+    totp_secret VARCHAR(64) NULL,
+    totp_last_step BIGINT NOT NULL DEFAULT 0,
+    role VARCHAR(20) NOT NULL DEFAULT 'user',
+    disabled BOOLEAN NOT NULL DEFAULT FALSE,
+    avatar_version BIGINT NOT NULL DEFAULT 0,
+    CONSTRAINT users_uc_email UNIQUE (email),
+    CONSTRAINT users_uc_handle UNIQUE (handle)
+);
+
+CREATE TABLE snippets (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NULL,
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    created TIMESTAMP NOT NULL,
+    expires TIMESTAMP NOT NULL,
+    CONSTRAINT snippets_fk_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_snippets_created ON snippets(created);
+
+INSERT INTO snippets (title, content, created, expires) VALUES (
+    'An old silent pond',
+    E'An old silent pond...\nA frog jumps into the pond,\nsplash! Silence again.\n\n– Matsuo Bashō',
+    NOW() AT TIME ZONE 'UTC',
+    NOW() AT TIME ZONE 'UTC' + INTERVAL '365 days'
+);
+
+INSERT INTO snippets (title, content, created, expires) VALUES (
+    'Over the wintry forest',
+    E'Over the wintry\nforest, winds howl in rage\nwith no leaves to blow.\n\n– Natsume Soseki',
+    NOW() AT TIME ZONE 'UTC',
+    NOW() AT TIME ZONE 'UTC' + INTERVAL '365 days'
+);
+
+INSERT INTO snippets (title, content, created, expires) VALUES (
+    'First autumn morning',
+    E'First autumn morning\nthe mirror I stare into\nshows my father''s face.\n\n– Murakami Kijo',
+    NOW() AT TIME ZONE 'UTC',
+    NOW() AT TIME ZONE 'UTC' + INTERVAL '7 days'
+);
+
+-- session 数据，由 database.SessionStore 使用
+-- The session data, used by database.SessionStore.
+CREATE TABLE sessions (
+    token CHAR(43) PRIMARY KEY,
+    data BYTEA NOT NULL,
+    expiry TIMESTAMP(6) NOT NULL
+);
+
+CREATE INDEX sessions_expiry_idx ON sessions (expiry);
+
+CREATE TABLE tokens (
+    hash CHAR(64) NOT NULL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    scope VARCHAR(32) NOT NULL,
+    created TIMESTAMP NOT NULL,
+    expiry TIMESTAMP NOT NULL,
+    CONSTRAINT tokens_fk_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_tokens_user_scope ON tokens(user_id, scope);
+
+CREATE TABLE recovery_codes (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    hashed_code CHAR(64) NOT NULL,
+    created TIMESTAMP NOT NULL,
+    CONSTRAINT recovery_codes_fk_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_recovery_codes_user ON recovery_codes(user_id);
+
+CREATE TABLE login_attempts (
+    id SERIAL PRIMARY KEY,
+    email VARCHAR(255) NOT NULL,
+    ip VARCHAR(45) NOT NULL,
+    result VARCHAR(16) NOT NULL,
+    created TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_login_attempts_email ON login_attempts(email, created);
+CREATE INDEX idx_login_attempts_ip ON login_attempts(ip, created);
+
+CREATE TABLE user_sessions (
+    id SERIAL PRIMARY KEY,
+    token CHAR(43) NOT NULL,
+    user_id INTEGER NOT NULL,
+    ip VARCHAR(45) NOT NULL,
+    user_agent VARCHAR(512) NOT NULL,
+    device VARCHAR(100) NOT NULL,
+    created TIMESTAMP NOT NULL,
+    last_seen TIMESTAMP NOT NULL,
+    CONSTRAINT user_sessions_uc_token UNIQUE (token),
+    CONSTRAINT user_sessions_fk_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_user_sessions_user ON user_sessions(user_id);
+
+CREATE TABLE user_identities (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    issuer VARCHAR(255) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    created TIMESTAMP NOT NULL,
+    CONSTRAINT user_identities_uc_issuer_subject UNIQUE (issuer, subject),
+    CONSTRAINT user_identities_fk_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE TABLE invitations (
+    id SERIAL PRIMARY KEY,
+    hash CHAR(64) NOT NULL,
+    created_by INTEGER NOT NULL,
+    created TIMESTAMP NOT NULL,
+    expiry TIMESTAMP NOT NULL,
+    used_by INTEGER NULL,
+    used TIMESTAMP NULL,
+    CONSTRAINT invitations_uc_hash UNIQUE (hash),
+    CONSTRAINT invitations_fk_created_by FOREIGN KEY (created_by) REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT invitations_fk_used_by FOREIGN KEY (used_by) REFERENCES users (id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_invitations_created_by ON invitations(created_by);
+
+CREATE TABLE audit_events (
+    id BIGSERIAL PRIMARY KEY,
+    action VARCHAR(40) NOT NULL,
+    actor_id INTEGER NULL,
+    subject VARCHAR(255) NOT NULL,
+    ip VARCHAR(45) NOT NULL,
+    user_agent VARCHAR(512) NOT NULL,
+    detail VARCHAR(255) NOT NULL,
+    created TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_audit_events_created ON audit_events(created);
+CREATE INDEX idx_audit_events_actor_id ON audit_events(actor_id);
+CREATE INDEX idx_audit_events_action ON audit_events(action);
+CREATE INDEX idx_audit_events_ip ON audit_events(ip);
@@ -0,0 +1,159 @@
+-- SQLite 数据库的完整结构，相当于 MySQL 的 1 到 15 号迁移。之后的迁移和 MySQL 使用相同的版本号。
+-- SQLite 只会报告冲突的列，所以唯一约束写成按 <表名>_uc_<列名> 命名的唯一索引
+-- The whole schema for SQLite, equivalent to MySQL's migrations 1 to 15.
+-- Later migrations use the same version numbers as MySQL's. SQLite only
+-- reports the columns in a unique key violation, so unique constraints are
+-- unique indexes named <table>_uc_<columns>, which is what the database
+-- package expects.
+CREATE TABLE users (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(255) NOT NULL,
+    handle VARCHAR(30) NULL,
+    email VARCHAR(255) NOT NULL,
+    hashed_password VARCHAR(255) NOT NULL,
+    created DATETIME NOT NULL,
+    email_verified BOOLEAN NOT NULL DEFAULT FALSE,
+    totp_secret VARCHAR(64) NULL,
+    totp_last_step BIGINT NOT NULL DEFAULT 0,
+    role VARCHAR(20) NOT NULL DEFAULT 'user',
+    disabled BOOLEAN NOT NULL DEFAULT FALSE,
+    avatar_version BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE UNIQUE INDEX users_uc_email ON users(email);
+CREATE UNIQUE INDEX users_uc_handle ON users(handle);
+
+CREATE TABLE snippets (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NULL,
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    created DATETIME NOT NULL,
+    expires DATETIME NOT NULL,
+    CONSTRAINT snippets_fk_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_snippets_created ON snippets(created);
+
+-- 时间使用和 go-sqlite3 写入时相同的格式，这样可以按文本比较
+-- Times are written in the same format go-sqlite3 uses, so that they compare
+-- correctly as text.
+INSERT INTO snippets (title, content, created, expires) VALUES (
+    'An old silent pond',
+    'An old silent pond...' || char(10) || 'A frog jumps into the pond,' || char(10) || 'splash! Silence again.' || char(10) || char(10) || '– Matsuo Bashō',
+    strftime('%Y-%m-%d %H:%M:%S+00:00', 'now'),
+    strftime('%Y-%m-%d %H:%M:%S+00:00', 'now', '+365 days')
+);
+
+INSERT INTO snippets (title, content, created, expires) VALUES (
+    'Over the wintry forest',
+    'Over the wintry' || char(10) || 'forest, winds howl in rage' || char(10) || 'with no leaves to blow.' || char(10) || char(10) || '– Natsume Soseki',
+    strftime('%Y-%m-%d %H:%M:%S+00:00', 'now'),
+    strftime('%Y-%m-%d %H:%M:%S+00:00', 'now', '+365 days')
+);
+
+INSERT INTO snippets (title, content, created, expires) VALUES (
+    'First autumn morning',
+    'First autumn morning' || char(10) || 'the mirror I stare into' || char(10) || 'shows my father''s face.' || char(10) || char(10) || '– Murakami Kijo',
+    strftime('%Y-%m-%d %H:%M:%S+00:00', 'now'),
+    strftime('%Y-%m-%d %H:%M:%S+00:00', 'now', '+7 days')
+);
+
+-- session 数据，由 database.SessionStore 使用
+-- The session data, used by database.SessionStore.
+CREATE TABLE sessions (
+    token CHAR(43) PRIMARY KEY,
+    data BLOB NOT NULL,
+    expiry DATETIME NOT NULL
+);
+
+CREATE INDEX sessions_expiry_idx ON sessions (expiry);
+
+CREATE TABLE tokens (
+    hash CHAR(64) NOT NULL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    scope VARCHAR(32) NOT NULL,
+    created DATETIME NOT NULL,
+    expiry DATETIME NOT NULL,
+    CONSTRAINT tokens_fk_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_tokens_user_scope ON tokens(user_id, scope);
+
+CREATE TABLE recovery_codes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    hashed_code CHAR(64) NOT NULL,
+    created DATETIME NOT NULL,
+    CONSTRAINT recovery_codes_fk_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_recovery_codes_user ON recovery_codes(user_id);
+
+CREATE TABLE login_attempts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    email VARCHAR(255) NOT NULL,
+    ip VARCHAR(45) NOT NULL,
+    result VARCHAR(16) NOT NULL,
+    created DATETIME NOT NULL
+);
+
+CREATE INDEX idx_login_attempts_email ON login_attempts(email, created);
+CREATE INDEX idx_login_attempts_ip ON login_attempts(ip, created);
+
+CREATE TABLE user_sessions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    token CHAR(43) NOT NULL,
+    user_id INTEGER NOT NULL,
+    ip VARCHAR(45) NOT NULL,
+    user_agent VARCHAR(512) NOT NULL,
+    device VARCHAR(100) NOT NULL,
+    created DATETIME NOT NULL,
+    last_seen DATETIME NOT NULL,
+    CONSTRAINT user_sessions_fk_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX user_sessions_uc_token ON user_sessions(token);
+CREATE INDEX idx_user_sessions_user ON user_sessions(user_id);
+
+CREATE TABLE user_identities (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    issuer VARCHAR(255) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    created DATETIME NOT NULL,
+    CONSTRAINT user_identities_fk_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX user_identities_uc_issuer_subject ON user_identities(issuer, subject);
+
+CREATE TABLE invitations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    hash CHAR(64) NOT NULL,
+    created_by INTEGER NOT NULL,
+    created DATETIME NOT NULL,
+    expiry DATETIME NOT NULL,
+    used_by INTEGER NULL,
+    used DATETIME NULL,
+    CONSTRAINT invitations_fk_created_by FOREIGN KEY (created_by) REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT invitations_fk_used_by FOREIGN KEY (used_by) REFERENCES users (id) ON DELETE SET NULL
+);
+
+CREATE UNIQUE INDEX invitations_uc_hash ON invitations(hash);
+CREATE INDEX idx_invitations_created_by ON invitations(created_by);
+
+CREATE TABLE audit_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    action VARCHAR(40) NOT NULL,
+    actor_id INTEGER NULL,
+    subject VARCHAR(255) NOT NULL,
+    ip VARCHAR(45) NOT NULL,
+    user_agent VARCHAR(512) NOT NULL,
+    detail VARCHAR(255) NOT NULL,
+    created DATETIME NOT NULL
+);
+
+CREATE INDEX idx_audit_events_created ON audit_events(created);
+CREATE INDEX idx_audit_events_actor_id ON audit_events(actor_id);
+CREATE INDEX idx_audit_events_action ON audit_events(action);
+CREATE INDEX idx_audit_events_ip ON audit_events(ip);
@@ -0,0 +1,84 @@
+package migrate
+
+import "strings"
+
+// splitStatements splits a script into single statements at the semicolons
+// which end them. The MySQL driver runs one statement per Exec unless
+// multiStatements is turned on for the whole connection, which we'd rather
+// not do. Semicolons inside quotes and comments are left alone.
+// 在语句结尾的分号处把脚本拆分成单独的语句。除非整个连接都打开 multiStatements，MySQL 驱动每次 Exec
+// 只能执行一条语句，而我们不想打开它。引号和注释中的分号不会被拆分
+func splitStatements(script string) []string {
+	var statements []string
+	var current strings.Builder
+
+	flush := func() {
+		s := strings.TrimSpace(current.String())
+		if s != "" {
+			statements = append(statements, s)
+		}
+		current.Reset()
+	}
+
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+
+		switch {
+		// Line comments are dropped, as are block comments.
+		// 删除单行注释和块注释
+		case c == '#', c == '-' && isLineComment(script[i:]):
+			end := strings.IndexByte(script[i:], '\n')
+			if end < 0 {
+				i = len(script)
+			} else {
+				i += end
+				current.WriteByte('\n')
+			}
+
+		case c == '/' && strings.HasPrefix(script[i:], "/*"):
+			end := strings.Index(script[i+2:], "*/")
+			if end < 0 {
+				i = len(script)
+			} else {
+				i += end + 3
+			}
+
+		case c == '\'' || c == '"' || c == '`':
+			// Copy the quoted text as it is. A backslash escapes the next
+			// character, and a doubled quote is an escaped quote.
+			// 原样复制引号中的内容，反斜杠转义下一个字符，两个连续的引号表示一个引号
+			current.WriteByte(c)
+			for i++; i < len(script); i++ {
+				current.WriteByte(script[i])
+				if script[i] == '\\' && c != '`' && i+1 < len(script) {
+					i++
+					current.WriteByte(script[i])
+					continue
+				}
+				if script[i] == c {
+					if i+1 < len(script) && script[i+1] == c {
+						i++
+						current.WriteByte(script[i])
+						continue
+					}
+					break
+				}
+			}
+
+		case c == ';':
+			flush()
+
+		default:
+			current.WriteByte(c)
+		}
+	}
+	flush()
+
+	return statements
+}
+
+// isLineComment reports whether s starts with "--" followed by whitespace,
+// which is what MySQL needs to treat it as a comment.
+func isLineComment(s string) bool {
+	return len(s) >= 2 && s[:2] == "--" && (len(s) == 2 || strings.ContainsRune(" \t\r\n", rune(s[2])))
+}
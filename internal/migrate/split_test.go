@@ -0,0 +1,74 @@
+package migrate
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{
+			name:   "One statement",
+			script: "CREATE TABLE a (id INT);",
+			want:   []string{"CREATE TABLE a (id INT)"},
+		},
+		{
+			name:   "No final semicolon",
+			script: "CREATE TABLE a (id INT);\nCREATE TABLE b (id INT)\n",
+			want:   []string{"CREATE TABLE a (id INT)", "CREATE TABLE b (id INT)"},
+		},
+		{
+			name:   "Empty statements",
+			script: ";;\n  ;CREATE TABLE a (id INT);;",
+			want:   []string{"CREATE TABLE a (id INT)"},
+		},
+		{
+			name:   "Only comments",
+			script: "-- nothing to do; really\n/* still; nothing */\n# or here;\n",
+			want:   nil,
+		},
+		{
+			name:   "Line comments",
+			script: "-- create a; then b\nCREATE TABLE a (id INT); # done with a;\nCREATE TABLE b (id INT);",
+			want:   []string{"CREATE TABLE a (id INT)", "CREATE TABLE b (id INT)"},
+		},
+		{
+			name:   "Block comment",
+			script: "CREATE /* a; b */ TABLE a (id INT);",
+			want:   []string{"CREATE  TABLE a (id INT)"},
+		},
+		{
+			name:   "Double dash without a space",
+			script: "SELECT 1--1;",
+			want:   []string{"SELECT 1--1"},
+		},
+		{
+			name:   "Semicolons in quotes",
+			script: "INSERT INTO a VALUES ('x;y', \"z;\", `c;d`);SELECT 1;",
+			want:   []string{"INSERT INTO a VALUES ('x;y', \"z;\", `c;d`)", "SELECT 1"},
+		},
+		{
+			name:   "Escaped quotes",
+			script: `INSERT INTO a VALUES ('it''s;', 'it\'s;');SELECT 1;`,
+			want:   []string{`INSERT INTO a VALUES ('it''s;', 'it\'s;')`, "SELECT 1"},
+		},
+		{
+			name:   "Comment markers in quotes",
+			script: "INSERT INTO a VALUES ('-- not a comment;', '/* nor; this */');",
+			want:   []string{"INSERT INTO a VALUES ('-- not a comment;', '/* nor; this */')"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitStatements(tt.script)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
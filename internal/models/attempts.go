@@ -0,0 +1,146 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"snippetbox.ab.net/internal/database"
+	"time"
+)
+
+// Define the possible results of a login attempt. Blocked attempts were
+// refused by the throttle before the password was even checked, disabled ones
+// had the right password for an account an administrator has disabled.
+// 登录尝试的结果，blocked 表示因为限流被拒绝，没有检查密码；disabled 表示密码正确但账号已被管理员禁用
+const (
+	LoginSuccess  = "success"
+	LoginFailure  = "failure"
+	LoginBlocked  = "blocked"
+	LoginDisabled = "disabled"
+)
+
+type LoginAttempt struct {
+	ID      int
+	Email   string
+	IP      string
+	Result  string
+	Created time.Time
+}
+
+// LoginAttemptModel wraps the login_attempts table, which is used both to
+// throttle password guessing and as a record for administrators.
+// 登录尝试记录，既用于限制密码猜测，也供管理员查看
+type LoginAttemptModel struct {
+	DB *database.DB
+}
+
+// Record saves a login attempt.
+// 保存一次登录尝试
+func (m *LoginAttemptModel) Record(email, ip, result string) error {
+	stmt := `INSERT INTO login_attempts (email, ip, result, created) VALUES(?, ?, ?, ?)`
+
+	_, err := m.DB.Exec(stmt, email, ip, result, time.Now().UTC())
+	return err
+}
+
+// FailuresForEmail returns the number of failed attempts for an account since
+// the given time and the time of the most recent one. A successful login
+// wipes the slate clean, so only failures after the last success count.
+// 返回账号自 since 以来失败的次数和最后一次失败的时间，成功登录后之前的失败不再计算
+func (m *LoginAttemptModel) FailuresForEmail(email string, since time.Time) (int, time.Time, error) {
+	lastSuccess, err := m.latest("email", email, LoginSuccess, time.Time{})
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	if lastSuccess.After(since) {
+		since = lastSuccess
+	}
+
+	return m.countFailures("email", email, since)
+}
+
+// FailuresForIP returns the number of failed attempts from an IP address since
+// the given time and the time of the most recent one. Unlike for accounts a
+// success doesn't reset the count, otherwise an attacker could clear it by
+// logging in to their own account.
+// 返回某个 IP 自 since 以来失败的次数，和账号不同，成功登录不会清零，否则攻击者登录自己的账号就能清除计数
+func (m *LoginAttemptModel) FailuresForIP(ip string, since time.Time) (int, time.Time, error) {
+	return m.countFailures("ip", ip, since)
+}
+
+// countFailures counts the failures since the given time for an email or IP
+// address, depending on column, and finds the time of the most recent one.
+// 根据 column 统计某个邮箱或 IP 自 since 以来失败的次数，并找到最后一次失败的时间
+func (m *LoginAttemptModel) countFailures(column, key string, since time.Time) (int, time.Time, error) {
+	var n int
+
+	stmt := `SELECT COUNT(*) FROM login_attempts WHERE ` + column + ` = ? AND result = ? AND created > ?`
+	err := m.DB.QueryRow(stmt, key, LoginFailure, since).Scan(&n)
+	if err != nil || n == 0 {
+		return 0, time.Time{}, err
+	}
+
+	last, err := m.latest(column, key, LoginFailure, since)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	return n, last, nil
+}
+
+// latest returns the time of the most recent attempt with the given result
+// after since, or the zero time if there isn't one. It sorts rather than
+// using MAX(created), because SQLite returns aggregates of dates as text.
+// 返回 since 之后最近一次结果为 result 的尝试时间，没有时返回零值。这里用排序而不是 MAX(created)，
+// 因为 SQLite 对日期做聚合时返回的是文本
+func (m *LoginAttemptModel) latest(column, key, result string, since time.Time) (time.Time, error) {
+	var created time.Time
+
+	stmt := `SELECT created FROM login_attempts WHERE ` + column + ` = ? AND result = ? AND created > ?
+    ORDER BY created DESC LIMIT 1`
+	err := m.DB.QueryRow(stmt, key, result, since).Scan(&created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, err
+	}
+
+	return created, nil
+}
+
+// Latest returns up to limit of the most recent login attempts whose email or
+// IP address contains q, for administrators to review.
+// 返回最近的登录尝试（最多 limit 条），按邮箱或 IP 过滤，供管理员查看
+func (m *LoginAttemptModel) Latest(q string, limit int) ([]*LoginAttempt, error) {
+	stmt := `SELECT id, email, ip, result, created FROM login_attempts
+    WHERE email ` + m.DB.Like() + ` ? ESCAPE '!' OR ip ` + m.DB.Like() + ` ? ESCAPE '!'
+    ORDER BY id DESC LIMIT ?`
+
+	pattern := likePattern(q)
+
+	rows, err := m.DB.Query(stmt, pattern, pattern, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attempts := []*LoginAttempt{}
+
+	for rows.Next() {
+		a := &LoginAttempt{}
+
+		err = rows.Scan(&a.ID, &a.Email, &a.IP, &a.Result, &a.Created)
+		if err != nil {
+			return nil, err
+		}
+
+		attempts = append(attempts, a)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return attempts, nil
+}
@@ -0,0 +1,103 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoginAttemptModelFailures(t *testing.T) {
+	now := time.Now().UTC()
+
+	// Each attempt is from email and ip, minutes ago.
+	type attempt struct {
+		email, ip, result string
+		minutes           int
+	}
+
+	tests := []struct {
+		name         string
+		attempts     []attempt
+		emailCount   int
+		emailLastAgo int
+		ipCount      int
+	}{
+		{
+			name: "Counts failures",
+			attempts: []attempt{
+				{"alice@example.com", "192.0.2.1", LoginFailure, 3},
+				{"alice@example.com", "192.0.2.1", LoginFailure, 2},
+			},
+			emailCount: 2, emailLastAgo: 2, ipCount: 2,
+		},
+		{
+			name: "Outside the window",
+			attempts: []attempt{
+				{"alice@example.com", "192.0.2.1", LoginFailure, 120},
+				{"alice@example.com", "192.0.2.1", LoginFailure, 2},
+			},
+			emailCount: 1, emailLastAgo: 2, ipCount: 1,
+		},
+		{
+			name: "Success resets the account but not the IP",
+			attempts: []attempt{
+				{"alice@example.com", "192.0.2.1", LoginFailure, 5},
+				{"alice@example.com", "192.0.2.1", LoginFailure, 4},
+				{"alice@example.com", "192.0.2.1", LoginSuccess, 3},
+				{"alice@example.com", "192.0.2.1", LoginFailure, 2},
+			},
+			emailCount: 1, emailLastAgo: 2, ipCount: 3,
+		},
+		{
+			name: "Other accounts count for the IP",
+			attempts: []attempt{
+				{"bob@example.com", "192.0.2.1", LoginFailure, 3},
+				{"carol@example.com", "192.0.2.1", LoginFailure, 2},
+				{"alice@example.com", "192.0.2.2", LoginFailure, 1},
+			},
+			emailCount: 1, emailLastAgo: 1, ipCount: 2,
+		},
+		{
+			name: "Blocked attempts don't count",
+			attempts: []attempt{
+				{"alice@example.com", "192.0.2.1", LoginBlocked, 2},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &LoginAttemptModel{DB: newTestDB(t)}
+			for _, a := range tt.attempts {
+				_, err := m.DB.Exec(`INSERT INTO login_attempts (email, ip, result, created) VALUES(?, ?, ?, ?)`,
+					a.email, a.ip, a.result, now.Add(-time.Duration(a.minutes)*time.Minute))
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			since := now.Add(-time.Hour)
+
+			n, last, err := m.FailuresForEmail("alice@example.com", since)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != tt.emailCount {
+				t.Errorf("got %d failures for the account; want %d", n, tt.emailCount)
+			}
+			if want := now.Add(-time.Duration(tt.emailLastAgo) * time.Minute); n > 0 && !last.Equal(want) {
+				t.Errorf("got last failure %v; want %v", last, want)
+			}
+			if n == 0 && !last.IsZero() {
+				t.Errorf("got last failure %v with no failures; want the zero time", last)
+			}
+
+			n, _, err = m.FailuresForIP("192.0.2.1", since)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != tt.ipCount {
+				t.Errorf("got %d failures for the IP; want %d", n, tt.ipCount)
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package models
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+	"snippetbox.ab.net/internal/cache"
+)
+
+// SnippetCache wraps another SnippetStore, keeping recently viewed snippets
+// and the latest snippets in memory so that the home page and popular
+// snippets don't hit the store on every request. A cached snippet is kept
+// for at most the cache's TTL, and never beyond its own expiry. Concurrent
+// misses for the same thing share a single lookup.
+//
+// Inserts and deletes made through the cache invalidate what they affect.
+// Anything which changes snippets behind the cache's back, such as deleting
+// a user or changing their handle, should call Purge. Each process has its
+// own cache, so with several instances the TTL bounds how stale they can be.
+//
+// 包装另一个 SnippetStore，在内存中保存最近查看的 snippet 和最新的 snippet，
+// 这样首页和热门 snippet 不会每个请求都访问存储。缓存的 snippet 最多保存 TTL 这么久，
+// 并且不会超过它自己的过期时间。对同一内容的并发未命中共享一次查询。
+//
+// 通过缓存进行的插入和删除会使受影响的内容失效。其他绕过缓存修改 snippet 的操作，
+// 例如删除用户或修改用户的 handle，应该调用 Purge。每个进程有自己的缓存，
+// 所以运行多个实例时，TTL 决定了数据最多能过时多久
+type SnippetCache struct {
+	SnippetStore
+
+	ttl     time.Duration
+	entries *cache.LRU[int, *Snippet]
+	group   singleflight.Group
+
+	mu            sync.Mutex
+	gen           uint64 // incremented by every invalidation
+	latest        []*Snippet
+	latestExpires time.Time
+}
+
+// NewSnippetCache returns a cache in front of store which holds up to size
+// snippets, each for at most ttl.
+// 返回 store 前面的缓存，最多保存 size 个 snippet，每个最多保存 ttl
+func NewSnippetCache(store SnippetStore, size int, ttl time.Duration) *SnippetCache {
+	return &SnippetCache{
+		SnippetStore: store,
+		ttl:          ttl,
+		entries:      cache.New[int, *Snippet](size),
+	}
+}
+
+func (c *SnippetCache) Insert(ctx context.Context, userID int, title string, content string, expires int) (int, error) {
+	id, err := c.SnippetStore.Insert(ctx, userID, title, content, expires)
+	if err != nil {
+		return 0, err
+	}
+
+	c.mu.Lock()
+	c.gen++
+	c.latest = nil
+	c.mu.Unlock()
+
+	return id, nil
+}
+
+func (c *SnippetCache) Get(ctx context.Context, id int) (*Snippet, error) {
+	if s, ok := c.entries.Get(id); ok {
+		return copySnippet(s), nil
+	}
+
+	v, err := c.load(ctx, "get:"+strconv.Itoa(id), func(ctx context.Context, gen uint64) (any, error) {
+		s, err := c.SnippetStore.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+
+		c.mu.Lock()
+		if c.gen == gen {
+			c.entries.Add(id, s, c.expiry(s))
+		}
+		c.mu.Unlock()
+
+		return s, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return copySnippet(v.(*Snippet)), nil
+}
+
+func (c *SnippetCache) Latest(ctx context.Context) ([]*Snippet, error) {
+	// Someone who has just written a snippet gets a fresh list, in case the
+	// cached one came from a replica which hadn't caught up with it.
+	// 刚写过 snippet 的用户得到新的列表，因为缓存的列表可能来自还没有同步的只读副本
+	if !usePrimary(ctx) {
+		c.mu.Lock()
+		if c.latest != nil && time.Now().Before(c.latestExpires) {
+			snippets := c.latest
+			c.mu.Unlock()
+			return copySnippets(snippets), nil
+		}
+		c.mu.Unlock()
+	}
+
+	v, err := c.load(ctx, "latest", func(ctx context.Context, gen uint64) (any, error) {
+		snippets, err := c.SnippetStore.Latest(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		// The list is stale as soon as any snippet in it expires.
+		// 列表中任何一个 snippet 过期，列表就过时了
+		expires := time.Now().Add(c.ttl)
+		for _, s := range snippets {
+			if s.Expires.Before(expires) {
+				expires = s.Expires
+			}
+		}
+
+		c.mu.Lock()
+		if c.gen == gen {
+			c.latest, c.latestExpires = snippets, expires
+		}
+		c.mu.Unlock()
+
+		return snippets, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return copySnippets(v.([]*Snippet)), nil
+}
+
+func (c *SnippetCache) Delete(ctx context.Context, id int) error {
+	err := c.SnippetStore.Delete(ctx, id)
+
+	// Invalidate even if the delete failed, since it may have gone through
+	// anyway.
+	// 即使删除失败也要失效，因为删除可能已经生效了
+	c.mu.Lock()
+	c.gen++
+	c.entries.Remove(id)
+	c.latest = nil
+	c.mu.Unlock()
+
+	return err
+}
+
+// Purge empties the cache.
+// 清空缓存
+func (c *SnippetCache) Purge() {
+	c.mu.Lock()
+	c.gen++
+	c.entries.Purge()
+	c.latest = nil
+	c.mu.Unlock()
+}
+
+// load runs fetch once for all concurrent callers with the same key. fetch
+// is given the generation it started in, and should only cache its result
+// if nothing has been invalidated since, or it could put back something
+// that has just changed.
+//
+// The lookup is shared, so it doesn't use any one caller's context and
+// isn't abandoned when that caller goes away; the store's own query timeout
+// still applies. Each caller stops waiting when its own context is done.
+// Callers which must read from the primary share a separate lookup.
+//
+// 对相同 key 的所有并发调用者只运行一次 fetch。fetch 得到它开始时的代数，
+// 只有在此之后没有发生失效时才应该缓存结果，否则可能会放回刚刚被修改的内容。
+//
+// 查询是共享的，所以它不使用任何一个调用者的 context，某个调用者离开时也不会放弃查询；
+// 存储自己的查询超时仍然有效。每个调用者在自己的 context 结束时停止等待。
+// 必须从主库读取的调用者共享另一个查询
+func (c *SnippetCache) load(ctx context.Context, key string, fetch func(context.Context, uint64) (any, error)) (any, error) {
+	c.mu.Lock()
+	gen := c.gen
+	c.mu.Unlock()
+
+	shared := context.Background()
+	if usePrimary(ctx) {
+		key += ":primary"
+		shared = WithPrimary(shared)
+	}
+
+	ch := c.group.DoChan(key, func() (any, error) {
+		return fetch(shared, gen)
+	})
+
+	select {
+	case res := <-ch:
+		return res.Val, res.Err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// expiry returns when a cached copy of s should be dropped.
+// 返回 s 的缓存副本应该被丢弃的时间
+func (c *SnippetCache) expiry(s *Snippet) time.Time {
+	expires := time.Now().Add(c.ttl)
+	if s.Expires.Before(expires) {
+		return s.Expires
+	}
+	return expires
+}
+
+// copySnippet and copySnippets return copies of cached snippets, so that
+// callers can't change what's in the cache.
+// 返回缓存 snippet 的副本，这样调用者无法修改缓存中的内容
+func copySnippet(s *Snippet) *Snippet {
+	c := *s
+	return &c
+}
+
+func copySnippets(snippets []*Snippet) []*Snippet {
+	copies := make([]*Snippet, len(snippets))
+	for i, s := range snippets {
+		copies[i] = copySnippet(s)
+	}
+	return copies
+}
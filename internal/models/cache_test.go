@@ -0,0 +1,192 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingStore counts the Get and Latest calls which reach the store.
+type countingStore struct {
+	SnippetStore
+
+	mu     sync.Mutex
+	gets   int
+	latest int
+}
+
+func (s *countingStore) Get(ctx context.Context, id int) (*Snippet, error) {
+	s.mu.Lock()
+	s.gets++
+	s.mu.Unlock()
+	return s.SnippetStore.Get(ctx, id)
+}
+
+func (s *countingStore) Latest(ctx context.Context) ([]*Snippet, error) {
+	s.mu.Lock()
+	s.latest++
+	s.mu.Unlock()
+	return s.SnippetStore.Latest(ctx)
+}
+
+func TestSnippetCache(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name       string
+		ttl        time.Duration
+		run        func(t *testing.T, c *SnippetCache, id int)
+		wantGets   int
+		wantLatest int
+	}{
+		{
+			name: "Get is cached",
+			ttl:  time.Hour,
+			run: func(t *testing.T, c *SnippetCache, id int) {
+				c.Get(ctx, id)
+				c.Get(ctx, id)
+			},
+			wantGets: 1,
+		},
+		{
+			name: "Latest is cached",
+			ttl:  time.Hour,
+			run: func(t *testing.T, c *SnippetCache, id int) {
+				c.Latest(ctx)
+				c.Latest(ctx)
+			},
+			wantLatest: 1,
+		},
+		{
+			name: "Expires after the TTL",
+			ttl:  time.Millisecond,
+			run: func(t *testing.T, c *SnippetCache, id int) {
+				c.Get(ctx, id)
+				c.Latest(ctx)
+				time.Sleep(5 * time.Millisecond)
+				c.Get(ctx, id)
+				c.Latest(ctx)
+			},
+			wantGets:   2,
+			wantLatest: 2,
+		},
+		{
+			name: "Insert invalidates Latest",
+			ttl:  time.Hour,
+			run: func(t *testing.T, c *SnippetCache, id int) {
+				c.Latest(ctx)
+				_, err := c.Insert(ctx, 0, "Another", "Snippet", 7)
+				if err != nil {
+					t.Fatal(err)
+				}
+				snippets, _ := c.Latest(ctx)
+				if len(snippets) != 2 {
+					t.Errorf("got %d latest snippets; want 2", len(snippets))
+				}
+			},
+			wantLatest: 2,
+		},
+		{
+			name: "Delete invalidates",
+			ttl:  time.Hour,
+			run: func(t *testing.T, c *SnippetCache, id int) {
+				c.Get(ctx, id)
+				c.Latest(ctx)
+				err := c.Delete(ctx, id)
+				if err != nil {
+					t.Fatal(err)
+				}
+				_, err = c.Get(ctx, id)
+				if !errors.Is(err, ErrNoRecord) {
+					t.Errorf("got error %v after Delete; want ErrNoRecord", err)
+				}
+				snippets, _ := c.Latest(ctx)
+				if len(snippets) != 0 {
+					t.Errorf("got %d latest snippets after Delete; want 0", len(snippets))
+				}
+			},
+			wantGets:   2,
+			wantLatest: 2,
+		},
+		{
+			name: "Purge",
+			ttl:  time.Hour,
+			run: func(t *testing.T, c *SnippetCache, id int) {
+				c.Get(ctx, id)
+				c.Latest(ctx)
+				c.Purge()
+				c.Get(ctx, id)
+				c.Latest(ctx)
+			},
+			wantGets:   2,
+			wantLatest: 2,
+		},
+		{
+			name: "Primary reads skip the cached Latest",
+			ttl:  time.Hour,
+			run: func(t *testing.T, c *SnippetCache, id int) {
+				c.Latest(ctx)
+				c.Latest(WithPrimary(ctx))
+			},
+			wantLatest: 2,
+		},
+		{
+			name: "Callers get copies",
+			ttl:  time.Hour,
+			run: func(t *testing.T, c *SnippetCache, id int) {
+				s, _ := c.Get(ctx, id)
+				s.Title = "Changed"
+				s, _ = c.Get(ctx, id)
+				if s.Title != "Title" {
+					t.Errorf("got title %q; want the cached copy unchanged", s.Title)
+				}
+			},
+			wantGets: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snippets, _ := NewMemoryModels(nil, nil, nil)
+			id, err := snippets.Insert(ctx, 0, "Title", "Content", 7)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			store := &countingStore{SnippetStore: snippets}
+			tt.run(t, NewSnippetCache(store, 10, tt.ttl), id)
+
+			if store.gets != tt.wantGets || store.latest != tt.wantLatest {
+				t.Errorf("store got %d Gets and %d Latests; want %d and %d", store.gets, store.latest, tt.wantGets, tt.wantLatest)
+			}
+		})
+	}
+}
+
+func TestSnippetCacheSnippetExpiry(t *testing.T) {
+	ctx := context.Background()
+	snippets, _ := NewMemoryModels(nil, nil, nil)
+	store := &countingStore{SnippetStore: snippets}
+	c := NewSnippetCache(store, 10, time.Hour)
+
+	// A snippet that expires before the TTL isn't served after it expires.
+	// Insert takes days, so move the expiry by hand.
+	id, err := snippets.Insert(ctx, 0, "Title", "Content", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snippets.snippets[id].Expires = time.Now().Add(10 * time.Millisecond)
+
+	_, err = c.Get(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	_, err = c.Get(ctx, id)
+	if !errors.Is(err, ErrNoRecord) {
+		t.Errorf("got error %v after the snippet expired; want ErrNoRecord", err)
+	}
+}
@@ -14,4 +14,18 @@ var (
 	// Add a new ErrDuplicateEmail error. We'll use this later if a user
 	// tries to signup with an email address that's already in use.
 	ErrDuplicateEmail = errors.New("models: duplicate email")
+
+	// ErrDuplicateHandle is returned when a handle is already taken.
+	// handle 已被使用时返回
+	ErrDuplicateHandle = errors.New("models: duplicate handle")
+
+	// ErrAccountDisabled is returned by Authenticate when the password is
+	// right but an administrator has disabled the account.
+	// 密码正确但账号已被管理员禁用时返回
+	ErrAccountDisabled = errors.New("models: account disabled")
+
+	// ErrInvalidInvitation is returned when an invitation code doesn't
+	// exist, has expired or has already been used.
+	// 邀请码不存在、已过期或已被使用时返回
+	ErrInvalidInvitation = errors.New("models: invalid invitation")
 )
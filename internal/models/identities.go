@@ -0,0 +1,53 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"snippetbox.ab.net/internal/database"
+	"time"
+)
+
+// IdentityModel wraps the user_identities table, which links accounts at
+// external OpenID Connect providers to our users. An external account is
+// identified by its issuer and subject, never by its email address, because
+// email addresses can change hands.
+// 把外部 OpenID Connect 身份提供方的账号关联到我们的用户。外部账号由 issuer 和 subject 标识，
+// 而不是邮箱，因为邮箱可能会易主
+type IdentityModel struct {
+	DB *database.DB
+}
+
+// GetUserID returns the ID of the user linked to an external account, or
+// ErrNoRecord if it isn't linked.
+// 返回关联到外部账号的用户 ID，未关联时返回 ErrNoRecord
+func (m *IdentityModel) GetUserID(issuer, subject string) (int, error) {
+	stmt := `SELECT user_id FROM user_identities WHERE issuer = ? AND subject = ?`
+
+	var userID int
+	err := m.DB.QueryRow(stmt, issuer, subject).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNoRecord
+		}
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+// Insert links an external account to a user.
+// 把外部账号关联到用户
+func (m *IdentityModel) Insert(userID int, issuer, subject string) error {
+	stmt := `INSERT INTO user_identities (user_id, issuer, subject, created) VALUES(?, ?, ?, ?)`
+
+	_, err := m.DB.Exec(stmt, userID, issuer, subject, time.Now().UTC())
+	return err
+}
+
+// Linked reports whether a user has any external accounts linked.
+// 返回用户是否关联了外部账号
+func (m *IdentityModel) Linked(userID int) (bool, error) {
+	var linked bool
+	err := m.DB.QueryRow(`SELECT EXISTS(SELECT true FROM user_identities WHERE user_id = ?)`, userID).Scan(&linked)
+	return linked, err
+}
@@ -0,0 +1,142 @@
+package models
+
+import (
+	"database/sql"
+	"snippetbox.ab.net/internal/database"
+	"time"
+)
+
+type Invitation struct {
+	ID        int
+	CreatedBy int
+	Created   time.Time
+	Expires   time.Time
+	UsedBy    int       // 0 until the invitation is used, or if that user has since left
+	Used      time.Time // the zero time until the invitation is used
+}
+
+// IsUsed reports whether the invitation has been used to sign up.
+// 邀请是否已经被使用
+func (i *Invitation) IsUsed() bool {
+	return !i.Used.IsZero()
+}
+
+// IsExpired reports whether the invitation can no longer be used because
+// it's too old.
+// 邀请是否已经过期
+func (i *Invitation) IsExpired() bool {
+	return !i.IsUsed() && time.Now().After(i.Expires)
+}
+
+// InvitationModel wraps the invitations table. Like tokens, invitation codes
+// are only stored as a SHA-256 hash. They're redeemed by
+// UserModel.InsertInvited.
+// 和 token 一样，邀请码只保存 SHA-256 哈希值，通过 UserModel.InsertInvited 使用
+type InvitationModel struct {
+	DB *database.DB
+}
+
+// New creates an invitation from a user which expires after ttl, and returns
+// the plaintext code to hand out.
+// 新建一个邀请，返回用于分发的明文邀请码
+func (m *InvitationModel) New(createdBy int, ttl time.Duration) (string, error) {
+	plaintext, hash, err := generateToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now().UTC()
+
+	stmt := `INSERT INTO invitations (hash, created_by, created, expiry) VALUES(?, ?, ?, ?)`
+
+	_, err = m.DB.Exec(stmt, hash, createdBy, now, now.Add(ttl))
+	if err != nil {
+		return "", err
+	}
+
+	return plaintext, nil
+}
+
+// Redeem marks an invitation as used by a user. If the code is unknown,
+// expired or already used it returns ErrInvalidInvitation. It's for stores
+// which can't redeem the code in the same transaction as adding the user.
+// 把邀请标记为已被某个用户使用，邀请码不存在、过期或已被使用时返回 ErrInvalidInvitation。
+// 用于无法在插入用户的同一个事务中使用邀请码的存储
+func (m *InvitationModel) Redeem(plaintext string, userID int) error {
+	now := time.Now().UTC()
+
+	stmt := `UPDATE invitations SET used = ?, used_by = ? WHERE hash = ? AND used IS NULL AND expiry > ?`
+
+	result, err := m.DB.Exec(stmt, now, userID, hashToken(plaintext), now)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrInvalidInvitation
+	}
+
+	return nil
+}
+
+// Valid reports whether an invitation code could currently be used. It's
+// only a courtesy check for forms; InsertInvited checks again when it
+// redeems the code.
+// 检查邀请码当前是否可用，只是为了表单提示，InsertInvited 在使用时会再次检查
+func (m *InvitationModel) Valid(plaintext string) (bool, error) {
+	stmt := `SELECT EXISTS(SELECT true FROM invitations WHERE hash = ? AND used IS NULL AND expiry > ?)`
+
+	var exists bool
+	err := m.DB.QueryRow(stmt, hashToken(plaintext), time.Now().UTC()).Scan(&exists)
+	return exists, err
+}
+
+// Outstanding returns how many of a user's invitations are neither used nor
+// expired.
+// 返回用户发出的、尚未使用也没有过期的邀请数量
+func (m *InvitationModel) Outstanding(userID int) (int, error) {
+	stmt := `SELECT COUNT(*) FROM invitations WHERE created_by = ? AND used IS NULL AND expiry > ?`
+
+	var n int
+	err := m.DB.QueryRow(stmt, userID, time.Now().UTC()).Scan(&n)
+	return n, err
+}
+
+// ListForUser returns the invitations a user has created, newest first.
+// 返回用户创建的邀请，按时间倒序
+func (m *InvitationModel) ListForUser(userID int) ([]*Invitation, error) {
+	stmt := `SELECT id, created_by, created, expiry, used_by, used FROM invitations
+    WHERE created_by = ? ORDER BY id DESC`
+
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invitations := []*Invitation{}
+
+	for rows.Next() {
+		i := &Invitation{}
+		var usedBy sql.NullInt64
+		var used sql.NullTime
+
+		err = rows.Scan(&i.ID, &i.CreatedBy, &i.Created, &i.Expires, &usedBy, &used)
+		if err != nil {
+			return nil, err
+		}
+		i.UsedBy = int(usedBy.Int64)
+		i.Used = used.Time
+
+		invitations = append(invitations, i)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return invitations, nil
+}
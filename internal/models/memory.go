@@ -0,0 +1,569 @@
+package models
+
+import (
+	"context"
+	"snippetbox.ab.net/internal/database"
+	"snippetbox.ab.net/internal/password"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NewMemoryModels returns snippet and user stores which keep everything in
+// memory, for development and tests. Nothing survives a restart. The two
+// are returned together because snippets show their author's handle and
+// deleting a user deletes their snippets. Everything else about a user,
+// such as invitations, identities and sessions, is still kept in db by the
+// SQL models, and sessions is the session manager's store, which deleting
+// a user logs them out of.
+// 返回把所有数据保存在内存中的 snippet 和用户存储，用于开发和测试，重启后数据全部丢失。
+// 两者一起返回，因为 snippet 要显示作者的 handle，删除用户时也要删除其 snippet。
+// 用户的其他数据，例如邀请、身份和会话，仍然由 SQL 模型保存在 db 中。
+// sessions 是 session manager 的存储，删除用户时会从中退出登录
+func NewMemoryModels(passwords *password.Policy, db *database.DB, sessions SessionDeleter) (*MemorySnippetModel, *MemoryUserModel) {
+	snippets := &MemorySnippetModel{snippets: map[int]*Snippet{}}
+	users := &MemoryUserModel{
+		Passwords:   passwords,
+		DB:          db,
+		Invitations: &InvitationModel{DB: db},
+		Sessions:    sessions,
+		users:       map[int]*memoryUser{},
+		byEmail:     map[string]int{},
+		byHandle:    map[string]int{},
+		snippets:    snippets,
+	}
+	snippets.users = users
+
+	return snippets, users
+}
+
+// MemorySnippetModel is a SnippetStore which keeps snippets in memory.
+// 把 snippet 保存在内存中的 SnippetStore
+type MemorySnippetModel struct {
+	mu       sync.RWMutex
+	snippets map[int]*Snippet
+	lastID   int
+
+	users *MemoryUserModel
+}
+
+func (m *MemorySnippetModel) Insert(ctx context.Context, userID int, title string, content string, expires int) (int, error) {
+	now := time.Now().UTC()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.lastID++
+	m.snippets[m.lastID] = &Snippet{
+		ID:      m.lastID,
+		UserID:  userID,
+		Title:   title,
+		Content: content,
+		Created: now,
+		Expires: now.AddDate(0, 0, expires),
+	}
+
+	return m.lastID, nil
+}
+
+func (m *MemorySnippetModel) Get(ctx context.Context, id int) (*Snippet, error) {
+	m.mu.RLock()
+	s, ok := m.snippets[id]
+	if ok {
+		copied := *s
+		s = &copied
+	}
+	m.mu.RUnlock()
+
+	if !ok || !s.Expires.After(time.Now()) {
+		return nil, ErrNoRecord
+	}
+
+	m.addHandles([]*Snippet{s})
+	return s, nil
+}
+
+func (m *MemorySnippetModel) Latest(ctx context.Context) ([]*Snippet, error) {
+	now := time.Now()
+
+	return m.find(func(s *Snippet) bool { return s.Expires.After(now) }, true, 10), nil
+}
+
+// Search returns up to limit snippets whose title or content contains q,
+// ignoring case, newest first. Like SnippetModel.Search it includes expired
+// snippets.
+// 返回标题或内容包含 q（不区分大小写）的 snippet（最多 limit 条），和 SnippetModel.Search 一样包含已过期的 snippet
+func (m *MemorySnippetModel) Search(ctx context.Context, q string, limit int) ([]*Snippet, error) {
+	q = strings.ToLower(q)
+
+	return m.find(func(s *Snippet) bool {
+		return strings.Contains(strings.ToLower(s.Title), q) || strings.Contains(strings.ToLower(s.Content), q)
+	}, true, limit), nil
+}
+
+func (m *MemorySnippetModel) Delete(ctx context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.snippets[id]; !ok {
+		return ErrNoRecord
+	}
+	delete(m.snippets, id)
+
+	return nil
+}
+
+func (m *MemorySnippetModel) ListForUser(ctx context.Context, userID int) ([]*Snippet, error) {
+	return m.find(func(s *Snippet) bool { return s.UserID == userID }, false, 0), nil
+}
+
+func (m *MemorySnippetModel) LatestForUser(ctx context.Context, userID int, limit int) ([]*Snippet, error) {
+	now := time.Now()
+
+	return m.find(func(s *Snippet) bool { return s.UserID == userID && s.Expires.After(now) }, true, limit), nil
+}
+
+// find returns copies of the snippets which match, sorted by ID, and at
+// most limit of them unless limit is 0.
+// 返回符合条件的 snippet 的副本，按 ID 排序，limit 不为 0 时最多返回 limit 条
+func (m *MemorySnippetModel) find(match func(*Snippet) bool, newestFirst bool, limit int) []*Snippet {
+	snippets := []*Snippet{}
+
+	m.mu.RLock()
+	for _, s := range m.snippets {
+		if match(s) {
+			copied := *s
+			snippets = append(snippets, &copied)
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(snippets, func(i, j int) bool {
+		if newestFirst {
+			return snippets[i].ID > snippets[j].ID
+		}
+		return snippets[i].ID < snippets[j].ID
+	})
+	if limit > 0 && len(snippets) > limit {
+		snippets = snippets[:limit]
+	}
+
+	m.addHandles(snippets)
+	return snippets
+}
+
+// addHandles fills in the authors' handles. It's called without holding
+// the snippets lock, so that the two stores never wait on each other.
+// 填写作者的 handle。调用时没有持有 snippet 的锁，这样两个存储永远不会互相等待
+func (m *MemorySnippetModel) addHandles(snippets []*Snippet) {
+	m.users.mu.RLock()
+	defer m.users.mu.RUnlock()
+
+	for _, s := range snippets {
+		if u, ok := m.users.users[s.UserID]; ok {
+			s.AuthorHandle = u.Handle
+		}
+	}
+}
+
+// removeUser deletes a user's snippets, or with keep just removes them as
+// the author.
+// 删除用户的 snippet，keep 为 true 时只去掉作者信息
+func (m *MemorySnippetModel) removeUser(userID int, keep bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, s := range m.snippets {
+		if s.UserID != userID {
+			continue
+		}
+		if keep {
+			s.UserID = 0
+		} else {
+			delete(m.snippets, id)
+		}
+	}
+}
+
+// SessionDeleter is the part of a session manager's store which
+// MemoryUserModel needs to log a deleted user out.
+// MemoryUserModel 删除用户时用来让其退出登录的 session store 方法
+type SessionDeleter interface {
+	Delete(token string) error
+}
+
+// memoryUser is a user along with the columns User doesn't expose.
+type memoryUser struct {
+	User
+	totpLastStep int64
+}
+
+// MemoryUserModel is a UserStore which keeps users in memory. Email
+// addresses and handles are unique regardless of case, as they are with
+// MySQL's collation.
+// 把用户保存在内存中的 UserStore，和 MySQL 的排序规则一样，邮箱和 handle 的唯一性不区分大小写
+type MemoryUserModel struct {
+	Passwords   *password.Policy
+	DB          *database.DB
+	Invitations *InvitationModel
+	Sessions    SessionDeleter
+
+	mu       sync.RWMutex
+	users    map[int]*memoryUser
+	byEmail  map[string]int
+	byHandle map[string]int
+	lastID   int
+
+	snippets *MemorySnippetModel
+}
+
+func (m *MemoryUserModel) passwordPolicy() *password.Policy {
+	if m.Passwords == nil {
+		return password.DefaultPolicy()
+	}
+	return m.Passwords
+}
+
+func (m *MemoryUserModel) Insert(ctx context.Context, name, handle, email, password string) (int, error) {
+	return m.insert(name, handle, email, password, "")
+}
+
+// InsertInvited adds a new user like Insert, redeeming an invitation code.
+// The email address and handle are checked first, so that the code stays
+// unused if they're taken.
+// 和 Insert 一样插入新用户并使用邀请码。先检查邮箱和 handle，这样它们已被占用时邀请码仍然保持未使用状态
+func (m *MemoryUserModel) InsertInvited(ctx context.Context, name, handle, email, password, invitation string) (int, error) {
+	return m.insert(name, handle, email, password, invitation)
+}
+
+func (m *MemoryUserModel) insert(name, handle, email, password, invitation string) (int, error) {
+	// Hash before taking the lock; it's deliberately slow.
+	// 在加锁之前计算哈希，因为哈希故意设计得很慢
+	hashedPassword, err := m.passwordPolicy().Hash(password)
+	if err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.byEmail[strings.ToLower(email)]; ok {
+		return 0, ErrDuplicateEmail
+	}
+	if _, ok := m.byHandle[strings.ToLower(handle)]; ok && handle != "" {
+		return 0, ErrDuplicateHandle
+	}
+
+	id := m.lastID + 1
+
+	if invitation != "" {
+		err = m.Invitations.Redeem(invitation, id)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	m.lastID = id
+	m.users[id] = &memoryUser{User: User{
+		ID:             id,
+		Name:           name,
+		Handle:         handle,
+		Email:          email,
+		HashedPassword: []byte(hashedPassword),
+		Created:        time.Now().UTC(),
+		Role:           RoleUser,
+	}}
+	m.byEmail[strings.ToLower(email)] = id
+	if handle != "" {
+		m.byHandle[strings.ToLower(handle)] = id
+	}
+
+	return id, nil
+}
+
+func (m *MemoryUserModel) Authenticate(ctx context.Context, email, plaintext string) (int, error) {
+	m.mu.RLock()
+	u, ok := m.users[m.byEmail[strings.ToLower(email)]]
+	var id int
+	var hashedPassword string
+	var disabled bool
+	if ok {
+		id, hashedPassword, disabled = u.ID, string(u.HashedPassword), u.Disabled
+	}
+	m.mu.RUnlock()
+
+	if !ok {
+		return 0, ErrInvalidCredentials
+	}
+
+	match, err := password.Verify(plaintext, hashedPassword)
+	if err != nil {
+		return 0, err
+	}
+	if !match {
+		return 0, ErrInvalidCredentials
+	}
+
+	if disabled {
+		return 0, ErrAccountDisabled
+	}
+
+	// Upgrade a weak hash, unless it was changed in the meantime.
+	// 升级较弱的哈希，除非它在此期间已被修改
+	policy := m.passwordPolicy()
+	if policy.NeedsRehash(hashedPassword) {
+		newHash, err := policy.Hash(plaintext)
+		if err != nil {
+			return 0, err
+		}
+
+		m.mu.Lock()
+		if u, ok := m.users[id]; ok && string(u.HashedPassword) == hashedPassword {
+			u.HashedPassword = []byte(newHash)
+		}
+		m.mu.Unlock()
+	}
+
+	return id, nil
+}
+
+func (m *MemoryUserModel) Get(ctx context.Context, id int) (*User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.copyUser(id)
+}
+
+func (m *MemoryUserModel) GetByEmail(ctx context.Context, email string) (*User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	id, ok := m.byEmail[strings.ToLower(email)]
+	if !ok {
+		return nil, ErrNoRecord
+	}
+	return m.copyUser(id)
+}
+
+func (m *MemoryUserModel) GetByHandle(ctx context.Context, handle string) (*User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	id, ok := m.byHandle[strings.ToLower(handle)]
+	if !ok || handle == "" {
+		return nil, ErrNoRecord
+	}
+	return m.copyUser(id)
+}
+
+// copyUser returns a copy of the user with the given ID, or ErrNoRecord.
+// The caller must hold the lock.
+// 返回指定 ID 用户的副本，不存在时返回 ErrNoRecord，调用者需要持有锁
+func (m *MemoryUserModel) copyUser(id int) (*User, error) {
+	u, ok := m.users[id]
+	if !ok {
+		return nil, ErrNoRecord
+	}
+
+	copied := u.User
+	return &copied, nil
+}
+
+func (m *MemoryUserModel) SetHandle(ctx context.Context, id int, handle string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, ok := m.users[id]
+	if !ok {
+		return ErrNoRecord
+	}
+	if other, ok := m.byHandle[strings.ToLower(handle)]; ok && other != id && handle != "" {
+		return ErrDuplicateHandle
+	}
+
+	delete(m.byHandle, strings.ToLower(u.Handle))
+	u.Handle = handle
+	if handle != "" {
+		m.byHandle[strings.ToLower(handle)] = id
+	}
+
+	return nil
+}
+
+// update calls fn on the user with the given ID while holding the lock. It
+// returns ErrNoRecord if there's no such user.
+// 持有锁时对指定 ID 的用户调用 fn，用户不存在时返回 ErrNoRecord
+func (m *MemoryUserModel) update(id int, fn func(*memoryUser)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, ok := m.users[id]
+	if !ok {
+		return ErrNoRecord
+	}
+	fn(u)
+
+	return nil
+}
+
+func (m *MemoryUserModel) SetAvatarVersion(ctx context.Context, id int, version int64) error {
+	return m.update(id, func(u *memoryUser) { u.AvatarVersion = version })
+}
+
+func (m *MemoryUserModel) UpdatePassword(ctx context.Context, id int, plaintext string) error {
+	hashedPassword, err := m.passwordPolicy().Hash(plaintext)
+	if err != nil {
+		return err
+	}
+
+	return m.update(id, func(u *memoryUser) { u.HashedPassword = []byte(hashedPassword) })
+}
+
+func (m *MemoryUserModel) SetEmailVerified(ctx context.Context, id int) error {
+	return m.update(id, func(u *memoryUser) { u.EmailVerified = true })
+}
+
+func (m *MemoryUserModel) EnableTOTP(ctx context.Context, id int, secret string) error {
+	return m.update(id, func(u *memoryUser) { u.TOTPSecret, u.totpLastStep = secret, 0 })
+}
+
+func (m *MemoryUserModel) DisableTOTP(ctx context.Context, id int) error {
+	return m.update(id, func(u *memoryUser) { u.TOTPSecret, u.totpLastStep = "", 0 })
+}
+
+func (m *MemoryUserModel) UseTOTPStep(ctx context.Context, id int, step int64) (bool, error) {
+	used := false
+	err := m.update(id, func(u *memoryUser) {
+		if u.totpLastStep < step {
+			u.totpLastStep = step
+			used = true
+		}
+	})
+
+	return used, err
+}
+
+// Search returns up to limit users whose name, handle or email contains q,
+// ignoring case, newest first.
+// 返回名字、handle 或邮箱包含 q（不区分大小写）的用户（最多 limit 个），按注册时间倒序
+func (m *MemoryUserModel) Search(ctx context.Context, q string, limit int) ([]*User, error) {
+	q = strings.ToLower(q)
+	users := []*User{}
+
+	m.mu.RLock()
+	for _, u := range m.users {
+		if strings.Contains(strings.ToLower(u.Name), q) || strings.Contains(strings.ToLower(u.Handle), q) ||
+			strings.Contains(strings.ToLower(u.Email), q) {
+			copied := u.User
+			users = append(users, &copied)
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].ID > users[j].ID
+	})
+	if len(users) > limit {
+		users = users[:limit]
+	}
+
+	return users, nil
+}
+
+func (m *MemoryUserModel) SetDisabled(ctx context.Context, id int, disabled bool) error {
+	return m.update(id, func(u *memoryUser) { u.Disabled = disabled })
+}
+
+// Delete removes a user and either deletes their snippets or, if
+// keepSnippets is true, leaves them up without an author. Their data in the
+// SQL models is deleted in a transaction first, as UserModel.Delete does,
+// and then their sessions, so that every device is logged out.
+// 删除用户，并删除其 snippet，keepSnippets 为 true 时保留 snippet 但去掉作者信息。
+// 和 UserModel.Delete 一样，先在一个事务中删除用户在 SQL 模型中的数据，然后删除其 session，
+// 使所有设备都退出登录
+func (m *MemoryUserModel) Delete(ctx context.Context, id int, keepSnippets bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, ok := m.users[id]
+	if !ok {
+		return ErrNoRecord
+	}
+
+	tokens, err := m.deleteSQL(ctx, id, u.Email)
+	if err != nil {
+		return err
+	}
+
+	for _, token := range tokens {
+		err = m.Sessions.Delete(token)
+		if err != nil {
+			return err
+		}
+	}
+
+	delete(m.users, id)
+	delete(m.byEmail, strings.ToLower(u.Email))
+	if u.Handle != "" {
+		delete(m.byHandle, strings.ToLower(u.Handle))
+	}
+
+	m.snippets.removeUser(id, keepSnippets)
+	return nil
+}
+
+// deleteSQL deletes what the SQL models keep about a user, and returns the
+// tokens of their sessions.
+// 删除 SQL 模型中保存的用户数据，返回其 session 的 token
+func (m *MemoryUserModel) deleteSQL(ctx context.Context, id int, email string) ([]string, error) {
+	ctx, cancel := m.DB.WithTimeout(ctx)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `SELECT token FROM user_sessions WHERE user_id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []string
+	for rows.Next() {
+		var token string
+		err = rows.Scan(&token)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	stmts := []string{
+		`DELETE FROM user_sessions WHERE user_id = ?`,
+		`DELETE FROM tokens WHERE user_id = ?`,
+		`DELETE FROM recovery_codes WHERE user_id = ?`,
+		`DELETE FROM user_identities WHERE user_id = ?`,
+		`DELETE FROM invitations WHERE created_by = ?`,
+		`UPDATE invitations SET used_by = NULL WHERE used_by = ?`,
+	}
+	for _, stmt := range stmts {
+		_, err = tx.ExecContext(ctx, stmt, id)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM login_attempts WHERE email = ?`, email)
+	if err != nil {
+		return nil, err
+	}
+
+	return tokens, tx.Commit()
+}
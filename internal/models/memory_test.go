@@ -0,0 +1,112 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"github.com/alexedwards/scs/v2/memstore"
+	"testing"
+	"time"
+)
+
+func TestMemoryUserModelMissingUser(t *testing.T) {
+	ctx := context.Background()
+	_, users := NewMemoryModels(nil, nil, nil)
+
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{"SetHandle", func() error { return users.SetHandle(ctx, 1, "alice") }},
+		{"SetAvatarVersion", func() error { return users.SetAvatarVersion(ctx, 1, 2) }},
+		{"UpdatePassword", func() error { return users.UpdatePassword(ctx, 1, "correct horse battery staple") }},
+		{"SetEmailVerified", func() error { return users.SetEmailVerified(ctx, 1) }},
+		{"EnableTOTP", func() error { return users.EnableTOTP(ctx, 1, "JBSWY3DPEHPK3PXP") }},
+		{"DisableTOTP", func() error { return users.DisableTOTP(ctx, 1) }},
+		{"UseTOTPStep", func() error { _, err := users.UseTOTPStep(ctx, 1, 1); return err }},
+		{"SetDisabled", func() error { return users.SetDisabled(ctx, 1, true) }},
+		{"Delete", func() error { return users.Delete(ctx, 1, false) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call()
+			if !errors.Is(err, ErrNoRecord) {
+				t.Errorf("got error %v; want ErrNoRecord", err)
+			}
+		})
+	}
+}
+
+func TestMemoryUserModelDelete(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	store := memstore.New()
+	t.Cleanup(store.StopCleanup)
+	_, users := NewMemoryModels(nil, db, store)
+
+	id, err := users.Insert(ctx, "Alice", "alice", "alice@example.com", "correct horse battery staple")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	identities := &IdentityModel{DB: db}
+	sessions := &SessionModel{DB: db}
+	tokens := &TokenModel{DB: db}
+
+	err = identities.Insert(id, "https://idp.example.com", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sessions.Insert("token", id, "192.0.2.1", "test", "Test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.Commit("token", []byte("data"), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = tokens.New(id, time.Hour, ScopePasswordReset)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = users.Delete(ctx, id, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"Identities", `SELECT COUNT(*) FROM user_identities WHERE user_id = ?`},
+		{"Sessions", `SELECT COUNT(*) FROM user_sessions WHERE user_id = ?`},
+		{"Tokens", `SELECT COUNT(*) FROM tokens WHERE user_id = ?`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var n int
+			err := db.QueryRow(tt.query, id).Scan(&n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != 0 {
+				t.Errorf("got %d rows left; want 0", n)
+			}
+		})
+	}
+
+	_, err = identities.GetUserID("https://idp.example.com", "alice")
+	if !errors.Is(err, ErrNoRecord) {
+		t.Errorf("got identity lookup error %v; want ErrNoRecord", err)
+	}
+
+	_, found, err := store.Find("token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found {
+		t.Error("session data still in the store")
+	}
+}
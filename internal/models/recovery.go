@@ -0,0 +1,120 @@
+package models
+
+import (
+	"crypto/rand"
+	"snippetbox.ab.net/internal/database"
+	"strings"
+	"time"
+)
+
+// recoveryAlphabet leaves out characters which are easy to confuse when
+// copied by hand (0/O, 1/I). It has exactly 32 characters, so picking one
+// with a random byte modulo 32 isn't biased.
+// 恢复码字符集，去掉了容易混淆的字符，正好 32 个字符保证随机分布均匀
+const recoveryAlphabet = "23456789ABCDEFGHJKLMNPQRSTUVWXYZ"
+
+// RecoveryCodeModel wraps the recovery_codes table. Recovery codes are
+// one-time codes a user can enter instead of a TOTP code if they lose their
+// authenticator. Like tokens, only a hash of each code is stored.
+// 恢复码可以在丢失身份验证器时代替验证码使用，和 token 一样只保存哈希值
+type RecoveryCodeModel struct {
+	DB *database.DB
+}
+
+// normalizeRecoveryCode strips the formatting a user might type so that
+// "abcde-fghij" and "ABCDEFGHIJ" are treated the same.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToUpper(code)
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
+
+func generateRecoveryCode() (string, error) {
+	b := make([]byte, 10)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	for i := range b {
+		b[i] = recoveryAlphabet[int(b[i])%len(recoveryAlphabet)]
+	}
+
+	return string(b[:5]) + "-" + string(b[5:]), nil
+}
+
+// Generate replaces any existing recovery codes for the user with n new ones
+// and returns the plaintext codes, which can only be shown to the user once.
+// 生成 n 个新的恢复码替换旧的，返回明文（只能展示给用户一次）
+func (m *RecoveryCodeModel) Generate(userID, n int) ([]string, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`DELETE FROM recovery_codes WHERE user_id = ?`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt := `INSERT INTO recovery_codes (user_id, hashed_code, created) VALUES(?, ?, ?)`
+	now := time.Now().UTC()
+
+	codes := make([]string, n)
+	for i := range codes {
+		codes[i], err = generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = tx.Exec(stmt, userID, hashToken(normalizeRecoveryCode(codes[i])), now)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// Use checks a recovery code and, if it's valid, deletes it so that it can't
+// be used again. It returns ErrNoRecord if the code isn't valid.
+// 校验恢复码，有效时删除它保证只能使用一次，无效时返回 ErrNoRecord
+func (m *RecoveryCodeModel) Use(userID int, code string) error {
+	stmt := `DELETE FROM recovery_codes WHERE user_id = ? AND hashed_code = ?`
+
+	result, err := m.DB.Exec(stmt, userID, hashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// Remaining returns how many unused recovery codes the user has left.
+// 返回用户剩余可用的恢复码数量
+func (m *RecoveryCodeModel) Remaining(userID int) (int, error) {
+	var n int
+	err := m.DB.QueryRow(`SELECT COUNT(*) FROM recovery_codes WHERE user_id = ?`, userID).Scan(&n)
+	return n, err
+}
+
+// DeleteAllForUser removes all of a user's recovery codes.
+// 删除用户所有的恢复码
+func (m *RecoveryCodeModel) DeleteAllForUser(userID int) error {
+	_, err := m.DB.Exec(`DELETE FROM recovery_codes WHERE user_id = ?`, userID)
+	return err
+}
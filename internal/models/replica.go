@@ -0,0 +1,23 @@
+package models
+
+import "context"
+
+type contextKey string
+
+const primaryContextKey = contextKey("primary")
+
+// WithPrimary returns a copy of ctx which makes models read from the
+// primary database even if they have a replica, for a user who has just
+// written something and must see it even if the replica hasn't caught up.
+// 返回 ctx 的副本，让模型即使有只读副本也从主库读取。用于刚写入数据的用户，
+// 即使副本还没有同步，也必须能看到自己写的内容
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryContextKey, true)
+}
+
+// usePrimary reports whether ctx came from WithPrimary.
+// 判断 ctx 是否来自 WithPrimary
+func usePrimary(ctx context.Context) bool {
+	primary, _ := ctx.Value(primaryContextKey).(bool)
+	return primary
+}
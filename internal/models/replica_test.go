@@ -0,0 +1,63 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestSnippetModelReplica(t *testing.T) {
+	// The replica never catches up, so anything read from it is missing.
+	m := &SnippetModel{DB: newTestDB(t), Replica: newTestDB(t)}
+
+	id, err := m.Insert(context.Background(), 0, "Only on the primary", "Content", 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		ctx   context.Context
+		found bool
+	}{
+		{"Replica", context.Background(), false},
+		{"Primary", WithPrimary(context.Background()), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := m.Get(tt.ctx, id)
+			if found := err == nil; found != tt.found {
+				t.Errorf("Get: got error %v; want found %t", err, tt.found)
+			}
+			if err != nil && !errors.Is(err, ErrNoRecord) {
+				t.Fatal(err)
+			}
+
+			latest, err := m.Latest(tt.ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if found := containsSnippet(latest, id); found != tt.found {
+				t.Errorf("Latest: got found %t; want %t", found, tt.found)
+			}
+
+			results, err := m.Search(tt.ctx, "only on the primary", 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if found := containsSnippet(results, id); found != tt.found {
+				t.Errorf("Search: got found %t; want %t", found, tt.found)
+			}
+		})
+	}
+}
+
+func containsSnippet(snippets []*Snippet, id int) bool {
+	for _, s := range snippets {
+		if s.ID == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,12 @@
+package models
+
+import "strings"
+
+// likePattern turns a search term into a LIKE pattern matching values which
+// contain it. Wildcards in the term are escaped with '!', so the queries using
+// it must say ESCAPE '!'.
+// 把搜索词转换成 LIKE 模式，搜索词中的通配符使用 '!' 转义，所以查询语句中需要写 ESCAPE '!'
+func likePattern(q string) string {
+	r := strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+	return "%" + r.Replace(q) + "%"
+}
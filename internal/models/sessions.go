@@ -0,0 +1,209 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"snippetbox.ab.net/internal/database"
+	"time"
+)
+
+// Session describes one logged in device. The Token is the scs session token,
+// which must never be shown to anyone, so pages refer to sessions by ID.
+// 一个已登录设备的会话，Token 是 scs 的 session token，不能展示出去，页面中使用 ID 引用会话
+type Session struct {
+	ID        int
+	Token     string
+	UserID    int
+	IP        string
+	UserAgent string
+	Device    string
+	Created   time.Time
+	LastSeen  time.Time
+}
+
+// SessionModel wraps the user_sessions table, an index from users to their
+// scs session tokens. The session data itself still lives in the session
+// store, which expires it on its own, so Lifetime and IdleTimeout should
+// match the store's: a session created longer than Lifetime ago, or last
+// seen longer than IdleTimeout ago, is treated as gone. 0 means no limit.
+// user_sessions 表是用户到 scs session token 的索引，session 数据本身仍然保存在 session store 中，
+// 由 session store 自己过期，所以 Lifetime 和 IdleTimeout 应该和 session store 的一致：
+// 创建时间早于 Lifetime 或最后活跃时间早于 IdleTimeout 的会话视为已经不存在。0 表示不限制
+type SessionModel struct {
+	DB          *database.DB
+	Lifetime    time.Duration
+	IdleTimeout time.Duration
+}
+
+// Insert adds a session to the index.
+// 添加一个会话到索引
+func (m *SessionModel) Insert(token string, userID int, ip, userAgent, device string) error {
+	now := time.Now().UTC()
+
+	stmt := `INSERT INTO user_sessions (token, user_id, ip, user_agent, device, created, last_seen)
+    VALUES(?, ?, ?, ?, ?, ?, ?)`
+
+	_, err := m.DB.Exec(stmt, token, userID, ip, userAgent, device, now, now)
+	return err
+}
+
+// Get returns the indexed session for a token, or ErrNoRecord.
+// 根据 token 获取会话，不存在时返回 ErrNoRecord
+func (m *SessionModel) Get(token string) (*Session, error) {
+	stmt := `SELECT id, token, user_id, ip, user_agent, device, created, last_seen
+    FROM user_sessions WHERE token = ?`
+
+	s := &Session{}
+	err := m.DB.QueryRow(stmt, token).Scan(&s.ID, &s.Token, &s.UserID, &s.IP, &s.UserAgent, &s.Device, &s.Created, &s.LastSeen)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Touch updates when a session was last seen and from which IP address.
+// 更新会话最后活跃的时间和 IP 地址
+func (m *SessionModel) Touch(token, ip string) error {
+	stmt := `UPDATE user_sessions SET last_seen = ?, ip = ? WHERE token = ?`
+
+	_, err := m.DB.Exec(stmt, time.Now().UTC(), ip, token)
+	return err
+}
+
+// ListForUser returns a user's sessions which haven't expired, most recently
+// used first.
+// 返回用户所有未过期的会话，最近使用的排在前面
+func (m *SessionModel) ListForUser(userID int) ([]*Session, error) {
+	stmt := `SELECT id, token, user_id, ip, user_agent, device, created, last_seen
+    FROM user_sessions WHERE user_id = ? AND created >= ? AND last_seen >= ?
+    ORDER BY last_seen DESC`
+
+	created, lastSeen := m.cutoffs()
+
+	rows, err := m.DB.Query(stmt, userID, created, lastSeen)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []*Session{}
+
+	for rows.Next() {
+		s := &Session{}
+		err = rows.Scan(&s.ID, &s.Token, &s.UserID, &s.IP, &s.UserAgent, &s.Device, &s.Created, &s.LastSeen)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// DeleteExpired removes the sessions which have expired from the index.
+// 从索引中删除已经过期的会话
+func (m *SessionModel) DeleteExpired() error {
+	created, lastSeen := m.cutoffs()
+
+	_, err := m.DB.Exec(`DELETE FROM user_sessions WHERE created < ? OR last_seen < ?`, created, lastSeen)
+	return err
+}
+
+// cutoffs returns the creation and last seen times before which a session
+// has expired. Without a limit it's the zero time, which nothing is before.
+// 返回会话过期的创建时间和最后活跃时间界限，不限制时返回零值时间，任何时间都不会早于它
+func (m *SessionModel) cutoffs() (created, lastSeen time.Time) {
+	now := time.Now().UTC()
+
+	if m.Lifetime > 0 {
+		created = now.Add(-m.Lifetime)
+	}
+	if m.IdleTimeout > 0 {
+		lastSeen = now.Add(-m.IdleTimeout)
+	}
+
+	return created, lastSeen
+}
+
+// Delete removes a session from the index by token.
+// 根据 token 从索引中删除会话
+func (m *SessionModel) Delete(token string) error {
+	_, err := m.DB.Exec(`DELETE FROM user_sessions WHERE token = ?`, token)
+	return err
+}
+
+// DeleteForUser removes one of a user's sessions by ID and returns its token
+// so that the session data can be deleted from the store as well. The user ID
+// is checked so that nobody can log out someone else's device.
+// 根据 ID 删除用户的一个会话并返回 token，以便同时从 session store 中删除数据，检查用户 ID 防止注销别人的设备
+func (m *SessionModel) DeleteForUser(userID, id int) (string, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var token string
+	stmt := `SELECT token FROM user_sessions WHERE id = ? AND user_id = ?`
+	err = tx.QueryRow(stmt, id, userID).Scan(&token)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrNoRecord
+		}
+		return "", err
+	}
+
+	_, err = tx.Exec(`DELETE FROM user_sessions WHERE id = ?`, id)
+	if err != nil {
+		return "", err
+	}
+
+	return token, tx.Commit()
+}
+
+// DeleteAllForUser removes all of a user's sessions except the one with the
+// token keep (which may be empty) and returns the deleted tokens.
+// 删除用户除 keep 之外的所有会话，返回被删除的 token
+func (m *SessionModel) DeleteAllForUser(userID int, keep string) ([]string, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT token FROM user_sessions WHERE user_id = ? AND token <> ?`, userID, keep)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := []string{}
+	for rows.Next() {
+		var token string
+		err = rows.Scan(&token)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`DELETE FROM user_sessions WHERE user_id = ? AND token <> ?`, userID, keep)
+	if err != nil {
+		return nil, err
+	}
+
+	return tokens, tx.Commit()
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSessionModelExpiry(t *testing.T) {
+	tests := []struct {
+		name     string
+		created  time.Duration // how long ago
+		lastSeen time.Duration
+		live     bool
+	}{
+		{name: "Fresh", created: time.Hour, lastSeen: time.Minute, live: true},
+		{name: "Idle", created: 2 * time.Hour, lastSeen: 2 * time.Hour, live: false},
+		{name: "Too old", created: 48 * time.Hour, lastSeen: time.Minute, live: false},
+	}
+
+	m := &SessionModel{DB: newTestDB(t), Lifetime: 24 * time.Hour, IdleTimeout: time.Hour}
+	now := time.Now().UTC()
+
+	for _, tt := range tests {
+		err := m.Insert(tt.name, 1, "192.0.2.1", "test", "Test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = m.DB.Exec(`UPDATE user_sessions SET created = ?, last_seen = ? WHERE token = ?`,
+			now.Add(-tt.created), now.Add(-tt.lastSeen), tt.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sessions, err := m.ListForUser(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listed := map[string]bool{}
+	for _, s := range sessions {
+		listed[s.Token] = true
+	}
+
+	err = m.DeleteExpired()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if listed[tt.name] != tt.live {
+				t.Errorf("listed = %t; want %t", listed[tt.name], tt.live)
+			}
+
+			_, err := m.Get(tt.name)
+			if tt.live && err != nil {
+				t.Errorf("after DeleteExpired: got error %v; want the session", err)
+			}
+			if !tt.live && !errors.Is(err, ErrNoRecord) {
+				t.Errorf("after DeleteExpired: got error %v; want ErrNoRecord", err)
+			}
+		})
+	}
+}
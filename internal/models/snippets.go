@@ -1,34 +1,79 @@
 package models
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"snippetbox.ab.net/internal/database"
 	"time"
 )
 
 type Snippet struct {
-	ID      int
-	Title   string
-	Content string
-	Created time.Time
-	Expires time.Time
+	ID           int
+	UserID       int    // 0 if the snippet doesn't belong to anyone
+	AuthorHandle string // "" if there's no author, or they haven't picked a handle
+	Title        string
+	Content      string
+	Created      time.Time
+	Expires      time.Time
 }
 
-// SnippetModel Define a SnippetModel type which wraps a sql.DB connection pool.
-type SnippetModel struct {
-	DB *sql.DB
-}
+// snippetColumns and snippetTables are used to select snippets together with
+// their author's handle, which scanSnippet reads.
+// 查询 snippet 以及作者 handle 时使用的字段和表，由 scanSnippet 读取
+const (
+	snippetColumns = `snippets.id, snippets.user_id, users.handle, snippets.title, snippets.content, snippets.created, snippets.expires`
+	snippetTables  = `snippets LEFT JOIN users ON users.id = snippets.user_id`
+)
 
-func (m *SnippetModel) Insert(title string, content string, expires int) (int, error) {
-	stmt := `INSERT INTO snippets (title, content, created, expires)
-    VALUES(?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))`
+func scanSnippet(row rowScanner) (*Snippet, error) {
+	s := &Snippet{}
+	var userID sql.NullInt64
+	var handle sql.NullString
 
-	result, err := m.DB.Exec(stmt, title, content, expires)
+	err := row.Scan(&s.ID, &userID, &handle, &s.Title, &s.Content, &s.Created, &s.Expires)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
+	s.UserID = int(userID.Int64)
+	s.AuthorHandle = handle.String
+
+	return s, nil
+}
+
+// SnippetModel Define a SnippetModel type which wraps a database connection pool.
+type SnippetModel struct {
+	DB *database.DB
+
+	// Replica, if it's set, is a read-only copy of DB which Get, Latest and
+	// Search read from, unless the context came from WithPrimary.
+	// 如果设置了 Replica，它是 DB 的只读副本，Get、Latest 和 Search 从它读取，
+	// 除非 context 来自 WithPrimary
+	Replica *database.DB
+}
+
+// reader returns the database to read from for ctx.
+// 返回 ctx 应该读取的数据库
+func (m *SnippetModel) reader(ctx context.Context) *database.DB {
+	if m.Replica == nil || usePrimary(ctx) {
+		return m.DB
+	}
+	return m.Replica
+}
+
+func (m *SnippetModel) Insert(ctx context.Context, userID int, title string, content string, expires int) (int, error) {
+	ctx, cancel := m.DB.WithTimeout(ctx)
+	defer cancel()
 
-	id, err := result.LastInsertId()
+	stmt := `INSERT INTO snippets (user_id, title, content, created, expires)
+    VALUES(?, ?, ?, ?, ?)`
+
+	// Work out the times here rather than with SQL date functions, which
+	// every database spells differently.
+	// 在这里计算时间，而不是使用 SQL 的日期函数，因为每种数据库的写法都不一样
+	now := time.Now().UTC()
+
+	id, err := m.DB.InsertIDContext(ctx, stmt, userID, title, content, now, now.AddDate(0, 0, expires))
 	if err != nil {
 		return 0, err
 	}
@@ -36,15 +81,18 @@ func (m *SnippetModel) Insert(title string, content string, expires int) (int, e
 	return int(id), nil
 }
 
-func (m *SnippetModel) Get(id int) (*Snippet, error) {
+func (m *SnippetModel) Get(ctx context.Context, id int) (*Snippet, error) {
+	db := m.reader(ctx)
 
-	stmt := `SELECT id, title, content, created, expires FROM snippets
-    WHERE expires > UTC_TIMESTAMP() AND id = ?`
+	ctx, cancel := db.WithTimeout(ctx)
+	defer cancel()
 
-	row := m.DB.QueryRow(stmt, id)
-	s := &Snippet{}
+	stmt := `SELECT ` + snippetColumns + ` FROM ` + snippetTables + `
+    WHERE snippets.expires > ? AND snippets.id = ?`
+
+	row := db.QueryRowContext(ctx, stmt, time.Now().UTC(), id)
 
-	err := row.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+	s, err := scanSnippet(row)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNoRecord
@@ -55,17 +103,24 @@ func (m *SnippetModel) Get(id int) (*Snippet, error) {
 	return s, nil
 }
 
-func (m *SnippetModel) Latest() ([]*Snippet, error) {
+func (m *SnippetModel) Latest(ctx context.Context) ([]*Snippet, error) {
+	// Give up on the query if it takes longer than the database's
+	// QueryTimeout, or the client goes away.
+	// 如果查询时间超过数据库的 QueryTimeout，或者客户端断开了连接，就放弃查询
+	db := m.reader(ctx)
+	ctx, cancel := db.WithTimeout(ctx)
+	defer cancel()
+
 	// Write the SQL statement we want to execute.
 	// SQL 语句
-	stmt := `SELECT id, title, content, created, expires FROM snippets
-    WHERE expires > UTC_TIMESTAMP() ORDER BY id DESC LIMIT 10`
+	stmt := `SELECT ` + snippetColumns + ` FROM ` + snippetTables + `
+    WHERE snippets.expires > ? ORDER BY snippets.id DESC LIMIT 10`
 
-	// Use the Query() method on the connection pool to execute our
+	// Use the QueryContext() method on the connection pool to execute our
 	// SQL statement. This returns a sql.Rows resultset containing the result of
 	// our query.
-	// 用 Query() 方法执行语句，返回查询到的所有行
-	rows, err := m.DB.Query(stmt)
+	// 用 QueryContext() 方法执行语句，返回查询到的所有行
+	rows, err := db.QueryContext(ctx, stmt, time.Now().UTC())
 	if err != nil {
 		return nil, err
 	}
@@ -90,15 +145,11 @@ func (m *SnippetModel) Latest() ([]*Snippet, error) {
 	// resultset automatically closes itself and frees-up the underlying
 	// database connection.
 	for rows.Next() {
-		// Create a pointer to a new zeroed Snippet struct.
-		s := &Snippet{}
-		// Use rows.Scan() to copy the values from each field in the row to the
-		// new Snippet object that we created. Again, the arguments to row.Scan()
-		// must be pointers to the place you want to copy the data into, and the
-		// number of arguments must be exactly the same as the number of
-		// columns returned by your statement.
-		// 用 Scan() 方法从原始数据复制到 Snippet 结构体中
-		err = rows.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+		// Use scanSnippet() to copy the values from each field in the row to
+		// a new Snippet object. The number of columns returned by the
+		// statement must be exactly the number scanSnippet() reads.
+		// 用 scanSnippet() 方法从原始数据复制到新的 Snippet 结构体中
+		s, err := scanSnippet(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -119,3 +170,93 @@ func (m *SnippetModel) Latest() ([]*Snippet, error) {
 	// 如果一切正常则返回切片 snippets
 	return snippets, nil
 }
+
+// Search returns up to limit snippets whose title or content contains q,
+// newest first. Unlike Latest it includes expired snippets, because it's used
+// by administrators.
+// 返回标题或内容包含 q 的 snippet（最多 limit 条），和 Latest 不同，这里包含已过期的 snippet，供管理员使用
+func (m *SnippetModel) Search(ctx context.Context, q string, limit int) ([]*Snippet, error) {
+	db := m.reader(ctx)
+
+	stmt := `SELECT ` + snippetColumns + ` FROM ` + snippetTables + `
+    WHERE snippets.title ` + db.Like() + ` ? ESCAPE '!' OR snippets.content ` + db.Like() + ` ? ESCAPE '!'
+    ORDER BY snippets.id DESC LIMIT ?`
+
+	pattern := likePattern(q)
+
+	return m.query(ctx, db, stmt, pattern, pattern, limit)
+}
+
+// Delete removes a snippet. If no matching snippet is found we return
+// ErrNoRecord.
+// 删除 snippet，没有找到时返回 ErrNoRecord
+func (m *SnippetModel) Delete(ctx context.Context, id int) error {
+	ctx, cancel := m.DB.WithTimeout(ctx)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, `DELETE FROM snippets WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// ListForUser returns every snippet the user has written, including expired
+// ones, oldest first. It's used to export the user's data.
+// 返回用户写过的所有 snippet（包括已过期的），用于导出用户数据
+func (m *SnippetModel) ListForUser(ctx context.Context, userID int) ([]*Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM ` + snippetTables + `
+    WHERE snippets.user_id = ? ORDER BY snippets.id`
+
+	return m.query(ctx, m.DB, stmt, userID)
+}
+
+// LatestForUser returns up to limit of the user's current snippets, newest
+// first, for their public profile.
+// 返回用户最近的未过期 snippet（最多 limit 条），用于公开的个人主页
+func (m *SnippetModel) LatestForUser(ctx context.Context, userID int, limit int) ([]*Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM ` + snippetTables + `
+    WHERE snippets.user_id = ? AND snippets.expires > ?
+    ORDER BY snippets.id DESC LIMIT ?`
+
+	return m.query(ctx, m.DB, stmt, userID, time.Now().UTC(), limit)
+}
+
+// query runs a statement selecting snippetColumns on db and returns the
+// snippets.
+func (m *SnippetModel) query(ctx context.Context, db *database.DB, stmt string, args ...any) ([]*Snippet, error) {
+	ctx, cancel := db.WithTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snippets := []*Snippet{}
+
+	for rows.Next() {
+		s, err := scanSnippet(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
@@ -0,0 +1,50 @@
+package models
+
+import "context"
+
+// SnippetStore is the set of snippet operations the application needs, so
+// that it doesn't depend on how or where snippets are stored. Every method
+// takes the request's context, so that work for a client which has gone
+// away can be abandoned.
+// 应用需要的 snippet 操作，这样应用不依赖 snippet 的存储方式。每个方法都接受请求的 context，
+// 这样客户端断开连接后可以放弃相应的工作
+type SnippetStore interface {
+	Insert(ctx context.Context, userID int, title string, content string, expires int) (int, error)
+	Get(ctx context.Context, id int) (*Snippet, error)
+	Latest(ctx context.Context) ([]*Snippet, error)
+	Search(ctx context.Context, q string, limit int) ([]*Snippet, error)
+	Delete(ctx context.Context, id int) error
+	ListForUser(ctx context.Context, userID int) ([]*Snippet, error)
+	LatestForUser(ctx context.Context, userID int, limit int) ([]*Snippet, error)
+}
+
+// UserStore is the set of user operations the application needs.
+// 应用需要的用户操作
+type UserStore interface {
+	Insert(ctx context.Context, name, handle, email, password string) (int, error)
+	InsertInvited(ctx context.Context, name, handle, email, password, invitation string) (int, error)
+	Authenticate(ctx context.Context, email, plaintext string) (int, error)
+	Get(ctx context.Context, id int) (*User, error)
+	GetByEmail(ctx context.Context, email string) (*User, error)
+	GetByHandle(ctx context.Context, handle string) (*User, error)
+	SetHandle(ctx context.Context, id int, handle string) error
+	SetAvatarVersion(ctx context.Context, id int, version int64) error
+	UpdatePassword(ctx context.Context, id int, plaintext string) error
+	SetEmailVerified(ctx context.Context, id int) error
+	EnableTOTP(ctx context.Context, id int, secret string) error
+	DisableTOTP(ctx context.Context, id int) error
+	UseTOTPStep(ctx context.Context, id int, step int64) (bool, error)
+	Search(ctx context.Context, q string, limit int) ([]*User, error)
+	SetDisabled(ctx context.Context, id int, disabled bool) error
+	Delete(ctx context.Context, id int, keepSnippets bool) error
+}
+
+// Check that the SQL and in-memory models implement the interfaces.
+// 确认 SQL 模型和内存模型实现了这些接口
+var (
+	_ SnippetStore = (*SnippetModel)(nil)
+	_ UserStore    = (*UserModel)(nil)
+
+	_ SnippetStore = (*MemorySnippetModel)(nil)
+	_ UserStore    = (*MemoryUserModel)(nil)
+)
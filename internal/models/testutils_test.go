@@ -0,0 +1,34 @@
+package models
+
+import (
+	"context"
+	"snippetbox.ab.net/internal/database"
+	"snippetbox.ab.net/internal/migrate"
+	"testing"
+)
+
+// newTestDB returns a new in-memory SQLite database with the schema
+// migrated, which is closed when the test finishes. Foreign keys are off so
+// that tests only need to create the rows they're about.
+// 返回一个已经迁移好表结构的内存 SQLite 数据库，测试结束时关闭。外键是关闭的，
+// 这样测试只需要创建和自己相关的行
+func newTestDB(t *testing.T) *database.DB {
+	t.Helper()
+
+	db, err := database.Open("sqlite::memory:?_foreign_keys=off")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrator, err := migrate.New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = migrator.Up(context.Background(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
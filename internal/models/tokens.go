@@ -0,0 +1,160 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"snippetbox.ab.net/internal/database"
+	"time"
+)
+
+// Define constants for the token scopes. A token can only ever be used for
+// the purpose it was issued for.
+// 定义 token 的用途，token 只能用于签发时指定的用途
+const (
+	ScopePasswordReset = "password-reset"
+	ScopeVerification  = "verification"
+)
+
+// TokenModel wraps the tokens table. Only a SHA-256 hash of each token is
+// stored, so a leaked database can't be used to reset anyone's password.
+// 数据库里只保存 token 的 SHA-256 哈希值，即使数据库泄露也无法直接使用
+type TokenModel struct {
+	DB *database.DB
+}
+
+// generateToken returns a random, URL-safe plaintext token together with
+// the hex encoded SHA-256 hash which is what gets stored in the database.
+func generateToken() (plaintext, hash string, err error) {
+	randomBytes := make([]byte, 16)
+	_, err = rand.Read(randomBytes)
+	if err != nil {
+		return "", "", err
+	}
+
+	plaintext = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes)
+	return plaintext, hashToken(plaintext), nil
+}
+
+func hashToken(plaintext string) string {
+	sum := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(sum[:])
+}
+
+// New creates a token for the user which expires after ttl and returns the
+// plaintext version to send to the user.
+// 新建一个 token，返回明文用于发送给用户
+func (m *TokenModel) New(userID int, ttl time.Duration, scope string) (string, error) {
+	plaintext, hash, err := generateToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now().UTC()
+
+	stmt := `INSERT INTO tokens (hash, user_id, scope, created, expiry)
+    VALUES(?, ?, ?, ?, ?)`
+
+	_, err = m.DB.Exec(stmt, hash, userID, scope, now, now.Add(ttl))
+	if err != nil {
+		return "", err
+	}
+
+	return plaintext, nil
+}
+
+// GetUserID returns the ID of the user a valid (unexpired) token belongs to,
+// without using it up. If the token doesn't exist or has expired we return
+// ErrNoRecord.
+// 查找未过期 token 对应的用户 ID，不会消耗 token
+func (m *TokenModel) GetUserID(scope, plaintext string) (int, error) {
+	stmt := `SELECT user_id FROM tokens WHERE hash = ? AND scope = ? AND expiry > ?`
+
+	var userID int
+	err := m.DB.QueryRow(stmt, hashToken(plaintext), scope, time.Now().UTC()).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNoRecord
+		}
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+// Consume looks up a valid token and deletes it in the same operation, so
+// that each token can only be used once even if two requests race each other.
+// 查找并删除 token，保证 token 只能使用一次
+func (m *TokenModel) Consume(scope, plaintext string) (int, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	hash := hashToken(plaintext)
+
+	var userID int
+	stmt := `SELECT user_id FROM tokens WHERE hash = ? AND scope = ? AND expiry > ?`
+	err = tx.QueryRow(stmt, hash, scope, time.Now().UTC()).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNoRecord
+		}
+		return 0, err
+	}
+
+	// Only the request which actually deletes the row gets to use the token.
+	// 只有真正删除了这一行的请求才算使用了 token
+	result, err := tx.Exec(`DELETE FROM tokens WHERE hash = ?`, hash)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		return 0, ErrNoRecord
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+// DeleteAllForUser removes every token with the given scope for a user.
+// 删除用户某个用途下的所有 token
+func (m *TokenModel) DeleteAllForUser(scope string, userID int) error {
+	stmt := `DELETE FROM tokens WHERE scope = ? AND user_id = ?`
+
+	_, err := m.DB.Exec(stmt, scope, userID)
+	return err
+}
+
+// LatestCreated returns when the most recent token with the given scope was
+// issued to the user, or the zero time if there isn't one. It's used to
+// throttle how often emails can be requested.
+// 返回用户最近一次签发该用途 token 的时间，用于限制邮件发送频率
+func (m *TokenModel) LatestCreated(scope string, userID int) (time.Time, error) {
+	stmt := `SELECT created FROM tokens WHERE scope = ? AND user_id = ?
+    ORDER BY created DESC LIMIT 1`
+
+	var created time.Time
+	err := m.DB.QueryRow(stmt, scope, userID).Scan(&created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, err
+	}
+
+	return created, nil
+}
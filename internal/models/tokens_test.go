@@ -0,0 +1,141 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTokenModel(t *testing.T) {
+	tests := []struct {
+		name    string
+		ttl     time.Duration
+		scope   string // used to look the token up
+		consume int    // times to consume it first
+		userID  int
+		err     error
+	}{
+		{name: "Valid", ttl: time.Hour, scope: ScopePasswordReset, userID: 1},
+		{name: "Wrong scope", ttl: time.Hour, scope: ScopeVerification, err: ErrNoRecord},
+		{name: "Expired", ttl: -time.Second, scope: ScopePasswordReset, err: ErrNoRecord},
+		{name: "Already used", ttl: time.Hour, scope: ScopePasswordReset, consume: 1, err: ErrNoRecord},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &TokenModel{DB: newTestDB(t)}
+
+			token, err := m.New(1, tt.ttl, ScopePasswordReset)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < tt.consume; i++ {
+				_, err = m.Consume(ScopePasswordReset, token)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			userID, err := m.GetUserID(tt.scope, token)
+			if userID != tt.userID || !errors.Is(err, tt.err) {
+				t.Errorf("GetUserID: got (%d, %v); want (%d, %v)", userID, err, tt.userID, tt.err)
+			}
+
+			userID, err = m.Consume(tt.scope, token)
+			if userID != tt.userID || !errors.Is(err, tt.err) {
+				t.Errorf("Consume: got (%d, %v); want (%d, %v)", userID, err, tt.userID, tt.err)
+			}
+		})
+	}
+}
+
+func TestTokenModelStoresHashes(t *testing.T) {
+	m := &TokenModel{DB: newTestDB(t)}
+
+	token, err := m.New(1, time.Hour, ScopePasswordReset)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var n int
+	err = m.DB.QueryRow(`SELECT COUNT(*) FROM tokens WHERE hash = ?`, token).Scan(&n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Error("the plaintext token is in the database")
+	}
+
+	_, err = m.GetUserID(ScopePasswordReset, token+"x")
+	if !errors.Is(err, ErrNoRecord) {
+		t.Errorf("got error %v for the wrong token; want ErrNoRecord", err)
+	}
+}
+
+func TestTokenModelDeleteAllForUser(t *testing.T) {
+	m := &TokenModel{DB: newTestDB(t)}
+
+	tokens := map[string]int{}
+	for _, userID := range []int{1, 1, 2} {
+		token, err := m.New(userID, time.Hour, ScopePasswordReset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens[token] = userID
+	}
+	err := m.DeleteAllForUser(ScopePasswordReset, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for token, userID := range tokens {
+		_, err := m.GetUserID(ScopePasswordReset, token)
+		if deleted := errors.Is(err, ErrNoRecord); deleted != (userID == 1) {
+			t.Errorf("user %d's token: got error %v", userID, err)
+		}
+	}
+}
+
+func TestTokenModelLatestCreated(t *testing.T) {
+	m := &TokenModel{DB: newTestDB(t)}
+
+	latest, err := m.LatestCreated(ScopeVerification, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !latest.IsZero() {
+		t.Errorf("got %v with no tokens; want the zero time", latest)
+	}
+
+	before := time.Now().Add(-time.Second)
+	_, err = m.New(1, time.Hour, ScopeVerification)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		scope  string
+		userID int
+		issued bool
+	}{
+		{"Same scope and user", ScopeVerification, 1, true},
+		{"Other scope", ScopePasswordReset, 1, false},
+		{"Other user", ScopeVerification, 2, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			latest, err := m.LatestCreated(tt.scope, tt.userID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.issued && latest.Before(before) {
+				t.Errorf("got %v; want after %v", latest, before)
+			}
+			if !tt.issued && !latest.IsZero() {
+				t.Errorf("got %v; want the zero time", latest)
+			}
+		})
+	}
+}
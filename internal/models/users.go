@@ -1,72 +1,181 @@
 package models
 
 import (
+	"context"
 	"database/sql"
 	"errors"
-	"github.com/go-sql-driver/mysql" // New import
-	"golang.org/x/crypto/bcrypt"     // New import
-	"strings"
+	"snippetbox.ab.net/internal/database"
+	"snippetbox.ab.net/internal/password"
 	"time"
 )
 
+// Define constants for the user roles. Every user has exactly one role.
+// 用户角色，每个用户只有一个角色
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 // Define a new User type. Notice how the field names and types align
 // with the columns in the database "users" table?
 // 定义 User 类型，字段和数据库表中的是一致的
 type User struct {
 	ID             int
 	Name           string
+	Handle         string // "" until the user has picked one
 	Email          string
 	HashedPassword []byte
 	Created        time.Time
+	EmailVerified  bool
+	TOTPSecret     string
+	Role           string
+	Disabled       bool
+
+	// AvatarVersion changes every time the user uploads an avatar, so that
+	// avatar URLs can be cached forever. It's 0 if they don't have one.
+	// 每次上传头像时都会改变，这样头像的 URL 可以被永久缓存，没有头像时为 0
+	AvatarVersion int64
+}
+
+// TOTPEnabled reports whether the user has set up two-factor authentication.
+// 用户是否开启了两步验证
+func (u *User) TOTPEnabled() bool {
+	return u.TOTPSecret != ""
+}
+
+// IsAdmin reports whether the user is an administrator.
+// 用户是否为管理员
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
 }
 
 // Define a new UserModel type which wraps a database connection pool.
+// Passwords is the policy used to hash new passwords; if it's nil the
+// default policy (bcrypt, cost 12) is used.
+// Passwords 是新密码使用的哈希策略，为 nil 时使用默认策略（bcrypt，cost 12）
 type UserModel struct {
-	DB *sql.DB
+	DB        *database.DB
+	Passwords *password.Policy
+}
+
+func (m *UserModel) passwordPolicy() *password.Policy {
+	if m.Passwords == nil {
+		return password.DefaultPolicy()
+	}
+	return m.Passwords
+}
+
+// execer is implemented by both *database.DB and *database.Tx.
+type execer interface {
+	InsertIDContext(ctx context.Context, query string, args ...any) (int64, error)
 }
 
-func (m *UserModel) Insert(name, email, password string) error {
-	// Create a bcrypt hash of the plain-text password.
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+// Insert adds a new, unverified user and returns the ID of the new record.
+// 插入新用户（邮箱未验证），返回新记录的 ID
+func (m *UserModel) Insert(ctx context.Context, name, handle, email, password string) (int, error) {
+	ctx, cancel := m.DB.WithTimeout(ctx)
+	defer cancel()
+
+	return m.insert(ctx, m.DB, name, handle, email, password)
+}
+
+// InsertInvited adds a new user like Insert, redeeming an invitation code in
+// the same transaction. If the code is unknown, expired or already used we
+// return ErrInvalidInvitation, and if the email address is taken the code
+// stays unused.
+// 和 Insert 一样插入新用户，并在同一个事务中使用邀请码。邀请码不存在、过期或已被使用时返回 ErrInvalidInvitation，
+// 如果邮箱已被占用，邀请码仍然保持未使用状态
+func (m *UserModel) InsertInvited(ctx context.Context, name, handle, email, password, invitation string) (int, error) {
+	ctx, cancel := m.DB.WithTimeout(ctx)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	hash := hashToken(invitation)
+	now := time.Now().UTC()
+
+	// Claiming the invitation first locks its row, so two signups can't
+	// both use it.
+	// 先占用邀请会锁住这一行，两个注册请求不能同时使用同一个邀请
+	stmt := `UPDATE invitations SET used = ? WHERE hash = ? AND used IS NULL AND expiry > ?`
+	result, err := tx.ExecContext(ctx, stmt, now, hash, now)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		return 0, ErrInvalidInvitation
 	}
 
-	stmt := `INSERT INTO users (name, email, hashed_password, created)
-    VALUES(?, ?, ?, UTC_TIMESTAMP())`
-
-	// Use the Exec() method to insert the user details and hashed password
-	// into the users table.
-	_, err = m.DB.Exec(stmt, name, email, string(hashedPassword))
-	if err != nil {
-		// If this returns an error, we use the errors.As() function to check
-		// whether the error has the type *mysql.MySQLError. If it does, the
-		// error will be assigned to the mySQLError variable. We can then check
-		// whether or not the error relates to our users_uc_email key by
-		// checking if the error code equals 1062 and the contents of the error
-		// message string. If it does, we return an ErrDuplicateEmail error.
-		var mySQLError *mysql.MySQLError
-		if errors.As(err, &mySQLError) {
-			if mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, "users_uc_email") {
-				return ErrDuplicateEmail
-			}
+	id, err := m.insert(ctx, tx, name, handle, email, password)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE invitations SET used_by = ? WHERE hash = ?`, id, hash)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// insert adds a user. An empty handle is stored as NULL, so that any number of
+// users can be without one.
+// 插入用户，空的 handle 保存为 NULL，这样可以有任意多个用户没有 handle
+func (m *UserModel) insert(ctx context.Context, db execer, name, handle, email, password string) (int, error) {
+	// Hash the plain-text password according to the current policy.
+	// 按照当前的策略对明文密码进行哈希
+	hashedPassword, err := m.passwordPolicy().Hash(password)
+	if err != nil {
+		return 0, err
+	}
+
+	stmt := `INSERT INTO users (name, handle, email, hashed_password, created)
+    VALUES(?, ?, ?, ?, ?)`
+
+	// Use the InsertID() method to insert the user details and hashed
+	// password into the users table and get the new user's ID.
+	id, err := db.InsertIDContext(ctx, stmt, name, nullString(handle), email, hashedPassword, time.Now().UTC())
+	if err != nil {
+		// If the email address or handle is already taken, return
+		// ErrDuplicateEmail or ErrDuplicateHandle.
+		// 邮箱或 handle 已被使用时返回 ErrDuplicateEmail 或 ErrDuplicateHandle
+		if dupErr := duplicateUserError(err); dupErr != nil {
+			return 0, dupErr
 		}
-		return err
+		return 0, err
 	}
 
-	return nil
+	return int(id), nil
 }
 
-func (m *UserModel) Authenticate(email, password string) (int, error) {
+func (m *UserModel) Authenticate(ctx context.Context, email, plaintext string) (int, error) {
+	ctx, cancel := m.DB.WithTimeout(ctx)
+	defer cancel()
+
 	// Retrieve the id and hashed password associated with the given email. If
 	// no matching email exists we return the ErrInvalidCredentials error.
 	// 先检查 email 是否存在
 	var id int
 	var hashedPassword []byte
+	var disabled bool
 
-	stmt := "SELECT id, hashed_password FROM users WHERE email = ?"
+	stmt := "SELECT id, hashed_password, disabled FROM users WHERE email = ?"
 
-	err := m.DB.QueryRow(stmt, email).Scan(&id, &hashedPassword)
+	err := m.DB.QueryRowContext(ctx, stmt, email).Scan(&id, &hashedPassword, &disabled)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return 0, ErrInvalidCredentials
@@ -77,11 +186,35 @@ func (m *UserModel) Authenticate(email, password string) (int, error) {
 
 	// Check whether the hashed password and plain-text password provided match.
 	// If they don't, we return the ErrInvalidCredentials error.
-	err = bcrypt.CompareHashAndPassword(hashedPassword, []byte(password))
+	match, err := password.Verify(plaintext, string(hashedPassword))
 	if err != nil {
-		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
-			return 0, ErrInvalidCredentials
-		} else {
+		return 0, err
+	}
+	if !match {
+		return 0, ErrInvalidCredentials
+	}
+
+	// Only say that the account is disabled to someone who knows the
+	// password.
+	// 只有知道密码的人才会被告知账号已被禁用
+	if disabled {
+		return 0, ErrAccountDisabled
+	}
+
+	// This is the only time we have the plain-text password, so if the stored
+	// hash is weaker than the current policy asks for, upgrade it now. The
+	// update only applies if the hash hasn't been changed in the meantime.
+	// 只有这时才能拿到明文密码，如果保存的哈希弱于当前策略，现在就升级。只有哈希在此期间没有被修改时才会更新
+	policy := m.passwordPolicy()
+	if policy.NeedsRehash(string(hashedPassword)) {
+		newHash, err := policy.Hash(plaintext)
+		if err != nil {
+			return 0, err
+		}
+
+		stmt = `UPDATE users SET hashed_password = ? WHERE id = ? AND hashed_password = ?`
+		_, err = m.DB.ExecContext(ctx, stmt, newHash, id, string(hashedPassword))
+		if err != nil {
 			return 0, err
 		}
 	}
@@ -95,3 +228,338 @@ func (m *UserModel) Authenticate(email, password string) (int, error) {
 func (m *UserModel) Exists(id int) (bool, error) {
 	return false, nil
 }
+
+// userColumns is the standard list of user columns read by scanUser.
+const userColumns = `id, name, handle, email, hashed_password, created, email_verified, totp_secret, role, disabled, avatar_version`
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanUser copies a row selected with userColumns into a new User.
+func (m *UserModel) scanUser(row rowScanner) (*User, error) {
+	u := &User{}
+	var handle, totpSecret sql.NullString
+
+	err := row.Scan(&u.ID, &u.Name, &handle, &u.Email, &u.HashedPassword, &u.Created, &u.EmailVerified, &totpSecret, &u.Role, &u.Disabled, &u.AvatarVersion)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+	u.Handle = handle.String
+	u.TOTPSecret = totpSecret.String
+
+	return u, nil
+}
+
+// Get returns the details of a specific user. If no matching user is found we
+// return ErrNoRecord.
+// 根据 ID 获取用户信息
+func (m *UserModel) Get(ctx context.Context, id int) (*User, error) {
+	ctx, cancel := m.DB.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `SELECT ` + userColumns + ` FROM users WHERE id = ?`
+
+	return m.scanUser(m.DB.QueryRowContext(ctx, stmt, id))
+}
+
+// GetByEmail returns the user with the given email address, or ErrNoRecord.
+// 根据 email 获取用户信息
+func (m *UserModel) GetByEmail(ctx context.Context, email string) (*User, error) {
+	ctx, cancel := m.DB.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `SELECT ` + userColumns + ` FROM users WHERE email = ?`
+
+	return m.scanUser(m.DB.QueryRowContext(ctx, stmt, email))
+}
+
+// GetByHandle returns the user with the given handle, or ErrNoRecord.
+// 根据 handle 获取用户信息
+func (m *UserModel) GetByHandle(ctx context.Context, handle string) (*User, error) {
+	ctx, cancel := m.DB.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `SELECT ` + userColumns + ` FROM users WHERE handle = ?`
+
+	return m.scanUser(m.DB.QueryRowContext(ctx, stmt, handle))
+}
+
+// SetHandle changes a user's handle. If another user already has it we
+// return ErrDuplicateHandle.
+// 修改用户的 handle，已被其他用户使用时返回 ErrDuplicateHandle
+func (m *UserModel) SetHandle(ctx context.Context, id int, handle string) error {
+	ctx, cancel := m.DB.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `UPDATE users SET handle = ? WHERE id = ?`
+
+	_, err := m.DB.ExecContext(ctx, stmt, handle, id)
+	if err != nil {
+		if dupErr := duplicateUserError(err); dupErr != nil {
+			return dupErr
+		}
+		return err
+	}
+
+	return nil
+}
+
+// SetAvatarVersion records that a user has uploaded a new avatar, or with
+// version 0 that they've removed it.
+// 记录用户上传了新头像，version 为 0 表示删除了头像
+func (m *UserModel) SetAvatarVersion(ctx context.Context, id int, version int64) error {
+	ctx, cancel := m.DB.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `UPDATE users SET avatar_version = ? WHERE id = ?`
+
+	_, err := m.DB.ExecContext(ctx, stmt, version, id)
+	return err
+}
+
+// duplicateUserError translates a unique key violation on the users table
+// into ErrDuplicateEmail or ErrDuplicateHandle, returning nil for any other
+// error.
+// 把 users 表的唯一键冲突转换成 ErrDuplicateEmail 或 ErrDuplicateHandle，其他错误返回 nil
+func duplicateUserError(err error) error {
+	// Each driver reports the violation in its own way, so leave it to the
+	// database package to check which of our unique keys it relates to.
+	// 每种驱动报告唯一键冲突的方式都不同，交给 database 包判断冲突的是哪个唯一键
+	switch {
+	case database.IsUniqueViolation(err, "users_uc_email"):
+		return ErrDuplicateEmail
+	case database.IsUniqueViolation(err, "users_uc_handle"):
+		return ErrDuplicateHandle
+	}
+	return nil
+}
+
+// nullString converts "" to NULL for storing.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// UpdatePassword replaces the stored hash for a user with a hash of the new
+// plain-text password.
+// 更新用户密码
+func (m *UserModel) UpdatePassword(ctx context.Context, id int, plaintext string) error {
+	hashedPassword, err := m.passwordPolicy().Hash(plaintext)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := m.DB.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `UPDATE users SET hashed_password = ? WHERE id = ?`
+
+	result, err := m.DB.ExecContext(ctx, stmt, hashedPassword, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// SetEmailVerified marks the email address of a user as verified.
+// 将用户的邮箱标记为已验证
+func (m *UserModel) SetEmailVerified(ctx context.Context, id int) error {
+	ctx, cancel := m.DB.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `UPDATE users SET email_verified = TRUE WHERE id = ?`
+
+	_, err := m.DB.ExecContext(ctx, stmt, id)
+	return err
+}
+
+// EnableTOTP turns on two-factor authentication for a user with the given
+// (already confirmed) secret.
+// 为用户开启两步验证，secret 需要已经确认过
+func (m *UserModel) EnableTOTP(ctx context.Context, id int, secret string) error {
+	ctx, cancel := m.DB.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `UPDATE users SET totp_secret = ?, totp_last_step = 0 WHERE id = ?`
+
+	_, err := m.DB.ExecContext(ctx, stmt, secret, id)
+	return err
+}
+
+// DisableTOTP turns off two-factor authentication for a user.
+// 关闭用户的两步验证
+func (m *UserModel) DisableTOTP(ctx context.Context, id int) error {
+	ctx, cancel := m.DB.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `UPDATE users SET totp_secret = NULL, totp_last_step = 0 WHERE id = ?`
+
+	_, err := m.DB.ExecContext(ctx, stmt, id)
+	return err
+}
+
+// UseTOTPStep records that the code for a time step has been used. It returns
+// false if that step (or a later one) was already used, so that a code which
+// has been seen once can't be replayed.
+// 记录某个时间步长的验证码已经被使用，如果已经用过则返回 false，防止验证码被重放
+func (m *UserModel) UseTOTPStep(ctx context.Context, id int, step int64) (bool, error) {
+	ctx, cancel := m.DB.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `UPDATE users SET totp_last_step = ? WHERE id = ? AND totp_last_step < ?`
+
+	result, err := m.DB.ExecContext(ctx, stmt, step, id, step)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n == 1, nil
+}
+
+// Search returns up to limit users whose name, handle or email contains q,
+// newest first.
+// 返回名字、handle 或邮箱包含 q 的用户（最多 limit 个），按注册时间倒序
+func (m *UserModel) Search(ctx context.Context, q string, limit int) ([]*User, error) {
+	ctx, cancel := m.DB.WithTimeout(ctx)
+	defer cancel()
+
+	like := m.DB.Like()
+	stmt := `SELECT ` + userColumns + ` FROM users
+    WHERE name ` + like + ` ? ESCAPE '!' OR handle ` + like + ` ? ESCAPE '!' OR email ` + like + ` ? ESCAPE '!'
+    ORDER BY id DESC LIMIT ?`
+
+	pattern := likePattern(q)
+
+	rows, err := m.DB.QueryContext(ctx, stmt, pattern, pattern, pattern, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []*User{}
+
+	for rows.Next() {
+		u, err := m.scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// SetDisabled disables or re-enables a user's account. Disabled users can't
+// log in.
+// 禁用或重新启用用户账号，被禁用的用户无法登录
+func (m *UserModel) SetDisabled(ctx context.Context, id int, disabled bool) error {
+	ctx, cancel := m.DB.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `UPDATE users SET disabled = ? WHERE id = ?`
+
+	result, err := m.DB.ExecContext(ctx, stmt, disabled, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		// MySQL doesn't count rows which already had the value, so check
+		// the user actually doesn't exist.
+		// MySQL 不统计值没有变化的行，所以需要确认用户确实不存在
+		_, err = m.Get(ctx, id)
+		return err
+	}
+
+	return nil
+}
+
+// Delete removes a user and everything that belongs to them in a single
+// transaction, including their session data so that every device is logged
+// out. If keepSnippets is true their snippets stay up but no longer name an
+// author, otherwise they are deleted too.
+// 在一个事务中删除用户及其所有数据，包括 session 数据，使所有设备都退出登录。
+// keepSnippets 为 true 时保留 snippet 但去掉作者信息，否则一并删除
+func (m *UserModel) Delete(ctx context.Context, id int, keepSnippets bool) error {
+	ctx, cancel := m.DB.WithTimeout(ctx)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var email string
+	err = tx.QueryRowContext(ctx, `SELECT email FROM users WHERE id = ?`+m.DB.ForUpdate(), id).Scan(&email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		}
+		return err
+	}
+
+	if keepSnippets {
+		_, err = tx.ExecContext(ctx, `UPDATE snippets SET user_id = NULL WHERE user_id = ?`, id)
+	} else {
+		_, err = tx.ExecContext(ctx, `DELETE FROM snippets WHERE user_id = ?`, id)
+	}
+	if err != nil {
+		return err
+	}
+
+	// The sessions table belongs to the session manager, but it's keyed by
+	// the same tokens as user_sessions.
+	// sessions 表属于 session manager，但它和 user_sessions 使用相同的 token
+	stmts := []string{
+		`DELETE FROM sessions WHERE token IN (SELECT token FROM user_sessions WHERE user_id = ?)`,
+		`DELETE FROM user_sessions WHERE user_id = ?`,
+		`DELETE FROM tokens WHERE user_id = ?`,
+		`DELETE FROM recovery_codes WHERE user_id = ?`,
+		`DELETE FROM user_identities WHERE user_id = ?`,
+		`DELETE FROM invitations WHERE created_by = ?`,
+		`UPDATE invitations SET used_by = NULL WHERE used_by = ?`,
+		`DELETE FROM users WHERE id = ?`,
+	}
+	for _, stmt := range stmts {
+		_, err = tx.ExecContext(ctx, stmt, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Login attempts are recorded by email address rather than user ID.
+	// 登录尝试是按邮箱记录的，而不是用户 ID
+	_, err = tx.ExecContext(ctx, `DELETE FROM login_attempts WHERE email = ?`, email)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
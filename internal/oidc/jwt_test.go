@@ -0,0 +1,250 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const (
+	testClientID = "snippetbox"
+	testNonce    = "nonce"
+)
+
+// testProvider runs a provider which publishes an RSA key with ID "rsa" and
+// a P-256 key with ID "ec", and returns it discovered along with the keys.
+func testProvider(t *testing.T) (*Provider, *rsa.PrivateKey, *ecdsa.PrivateKey) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b64 := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+	pad := func(n *big.Int) []byte { return n.FillBytes(make([]byte, 32)) }
+
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 srv.URL,
+			"authorization_endpoint": srv.URL + "/authorize",
+			"token_endpoint":         srv.URL + "/token",
+			"jwks_uri":               srv.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string][]jwk{"keys": {
+			{Kty: "RSA", Kid: "rsa", Use: "sig", N: b64(rsaKey.N.Bytes()), E: b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+			{Kty: "EC", Kid: "ec", Crv: "P-256", X: b64(pad(ecKey.X)), Y: b64(pad(ecKey.Y))},
+		}})
+	})
+
+	p, err := Discover(context.Background(), srv.URL, Config{ClientID: testClientID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return p, rsaKey, ecKey
+}
+
+// signToken returns a JWT with the given header and claims. key is an
+// *rsa.PrivateKey, an *ecdsa.PrivateKey, a []byte HMAC secret, or nil for
+// no signature at all.
+func signToken(t *testing.T, header, claims map[string]any, key any) string {
+	encode := func(v any) string {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+
+	signed := encode(header) + "." + encode(claims)
+	digest := sha256.Sum256([]byte(signed))
+
+	var sig []byte
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		var err error
+		sig, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	case []byte:
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestVerify(t *testing.T) {
+	p, rsaKey, ecKey := testProvider(t)
+	now := time.Now()
+
+	// claims returns valid claims with the changes applied; a nil value
+	// removes a claim.
+	claims := func(changes map[string]any) map[string]any {
+		c := map[string]any{
+			"iss":            p.Issuer,
+			"sub":            "alice",
+			"aud":            testClientID,
+			"exp":            now.Add(time.Hour).Unix(),
+			"iat":            now.Unix(),
+			"nonce":          testNonce,
+			"email":          "alice@example.com",
+			"email_verified": true,
+			"name":           "Alice",
+		}
+		for k, v := range changes {
+			if v == nil {
+				delete(c, k)
+			} else {
+				c[k] = v
+			}
+		}
+		return c
+	}
+
+	rs256 := map[string]any{"alg": "RS256", "kid": "rsa"}
+	es256 := map[string]any{"alg": "ES256", "kid": "ec"}
+	der, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaPublic := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+
+	tampered := signToken(t, rs256, claims(nil), rsaKey)
+	tampered = tampered[:len(tampered)-4] + "AAAA"
+
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{name: "RS256", token: signToken(t, rs256, claims(nil), rsaKey), valid: true},
+		{name: "ES256", token: signToken(t, es256, claims(nil), ecKey), valid: true},
+		{name: "Audience list with azp", token: signToken(t, rs256, claims(map[string]any{"aud": []string{testClientID, "other"}, "azp": testClientID}), rsaKey), valid: true},
+		{name: "Expired within skew", token: signToken(t, rs256, claims(map[string]any{"exp": now.Add(-30 * time.Second).Unix()}), rsaKey), valid: true},
+		{name: "Issued within skew", token: signToken(t, rs256, claims(map[string]any{"iat": now.Add(30 * time.Second).Unix()}), rsaKey), valid: true},
+
+		{name: "Malformed", token: "not.a-token"},
+		{name: "Bad signature", token: tampered},
+		{name: "Signed by another key", token: signToken(t, rs256, claims(nil), mustRSAKey(t))},
+		{name: "Algorithm none", token: signToken(t, map[string]any{"alg": "none", "kid": "rsa"}, claims(nil), nil)},
+		{name: "HS256 with the public key", token: signToken(t, map[string]any{"alg": "HS256", "kid": "rsa"}, claims(nil), rsaPublic)},
+		{name: "Algorithm doesn't match key", token: signToken(t, map[string]any{"alg": "ES256", "kid": "rsa"}, claims(nil), ecKey)},
+		{name: "Unknown key", token: signToken(t, map[string]any{"alg": "RS256", "kid": "other"}, claims(nil), rsaKey)},
+		{name: "Wrong issuer", token: signToken(t, rs256, claims(map[string]any{"iss": "https://evil.example.com"}), rsaKey)},
+		{name: "No subject", token: signToken(t, rs256, claims(map[string]any{"sub": nil}), rsaKey)},
+		{name: "Wrong audience", token: signToken(t, rs256, claims(map[string]any{"aud": "other"}), rsaKey)},
+		{name: "Audience list without azp", token: signToken(t, rs256, claims(map[string]any{"aud": []string{testClientID, "other"}}), rsaKey)},
+		{name: "Wrong azp", token: signToken(t, rs256, claims(map[string]any{"aud": []string{testClientID, "other"}, "azp": "other"}), rsaKey)},
+		{name: "Expired", token: signToken(t, rs256, claims(map[string]any{"exp": now.Add(-2 * time.Minute).Unix()}), rsaKey)},
+		{name: "Issued in the future", token: signToken(t, rs256, claims(map[string]any{"iat": now.Add(2 * time.Minute).Unix()}), rsaKey)},
+		{name: "Wrong nonce", token: signToken(t, rs256, claims(map[string]any{"nonce": "other"}), rsaKey)},
+		{name: "No nonce", token: signToken(t, rs256, claims(map[string]any{"nonce": nil}), rsaKey)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := p.Verify(context.Background(), tt.token, testNonce)
+			if !tt.valid {
+				if !errors.Is(err, ErrInvalidToken) {
+					t.Errorf("got error %v; want ErrInvalidToken", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.Subject != "alice" || c.Email != "alice@example.com" || !c.EmailVerified {
+				t.Errorf("got claims %+v", c)
+			}
+		})
+	}
+}
+
+func TestVerifyClaims(t *testing.T) {
+	p, rsaKey, _ := testProvider(t)
+	now := time.Now().Truncate(time.Second)
+
+	tests := []struct {
+		name          string
+		emailVerified any
+		authTime      any
+		wantVerified  bool
+		wantAuthTime  time.Time
+	}{
+		{name: "Boolean", emailVerified: true, authTime: now.Unix(), wantVerified: true, wantAuthTime: now},
+		{name: "String", emailVerified: "true", wantVerified: true},
+		{name: "False", emailVerified: false},
+		{name: "Missing"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := map[string]any{
+				"iss":   p.Issuer,
+				"sub":   "alice",
+				"aud":   testClientID,
+				"exp":   now.Add(time.Hour).Unix(),
+				"iat":   now.Unix(),
+				"nonce": testNonce,
+			}
+			if tt.emailVerified != nil {
+				claims["email_verified"] = tt.emailVerified
+			}
+			if tt.authTime != nil {
+				claims["auth_time"] = tt.authTime
+			}
+
+			c, err := p.Verify(context.Background(), signToken(t, map[string]any{"alg": "RS256", "kid": "rsa"}, claims, rsaKey), testNonce)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.EmailVerified != tt.wantVerified {
+				t.Errorf("got EmailVerified %t; want %t", c.EmailVerified, tt.wantVerified)
+			}
+			if !c.AuthTime.Equal(tt.wantAuthTime) {
+				t.Errorf("got AuthTime %v; want %v", c.AuthTime, tt.wantAuthTime)
+			}
+		})
+	}
+}
+
+func mustRSAKey(t *testing.T) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
@@ -0,0 +1,218 @@
+// Package oidc implements the relying party side of OpenID Connect login:
+// provider discovery, the authorization code flow with PKCE, and ID token
+// verification against the provider's published keys. Only the standard
+// library is used.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidToken   = errors.New("oidc: invalid ID token")
+	ErrExchange       = errors.New("oidc: code exchange failed")
+	ErrIssuerMismatch = errors.New("oidc: discovered issuer doesn't match")
+)
+
+// Config holds the client registration details for a provider.
+// 在身份提供方注册的客户端信息
+type Config struct {
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// Provider is an OpenID Connect provider whose configuration has been
+// discovered. It's safe for concurrent use.
+// 已经完成发现的 OpenID Connect 身份提供方，可以并发使用
+type Provider struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+
+	config Config
+	client *http.Client
+	keys   *keySet
+}
+
+// Discover fetches the provider's configuration from its well-known
+// discovery document. The issuer in the document must be exactly the one we
+// asked for, otherwise one provider could pretend to be another.
+// 从 well-known 地址获取身份提供方的配置，文档中的 issuer 必须和请求的完全一致，防止冒充
+func Discover(ctx context.Context, issuer string, config Config) (*Provider, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	wellKnown := strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration"
+
+	p := &Provider{}
+	err := getJSON(ctx, client, wellKnown, p)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: discovery: %w", err)
+	}
+
+	if p.Issuer != issuer {
+		return nil, fmt.Errorf("%w: expected %q, got %q", ErrIssuerMismatch, issuer, p.Issuer)
+	}
+	if p.AuthorizationEndpoint == "" || p.TokenEndpoint == "" || p.JWKSURI == "" {
+		return nil, errors.New("oidc: discovery document is missing endpoints")
+	}
+
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "email", "profile"}
+	}
+
+	p.config = config
+	p.client = client
+	p.keys = newKeySet(client, p.JWKSURI)
+
+	return p, nil
+}
+
+// getJSON GETs url and decodes the JSON response body into v.
+func getJSON(ctx context.Context, client *http.Client, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+// randomString returns n random bytes, base64url encoded.
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// NewState returns a random value suitable for the state or nonce
+// parameters, and NewVerifier a random PKCE code verifier.
+// 生成随机的 state/nonce 参数，以及随机的 PKCE code verifier
+func NewState() (string, error) {
+	return randomString(16)
+}
+
+func NewVerifier() (string, error) {
+	// 32 bytes gives a 43 character verifier, the minimum RFC 7636 allows.
+	// 32 字节编码后是 43 个字符，正好是 RFC 7636 允许的最短长度
+	return randomString(32)
+}
+
+// Challenge returns the S256 PKCE code challenge for a verifier.
+// 返回 verifier 对应的 S256 PKCE code challenge
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthCodeURL returns the URL to send the user to at the provider to log in.
+// 返回跳转到身份提供方进行登录的 URL
+func (p *Provider) AuthCodeURL(state, nonce, verifier string) string {
+	return p.authCodeURL(state, nonce, verifier, nil)
+}
+
+// ReauthCodeURL is like AuthCodeURL, but asks the provider to make the user
+// log in again even if they're still logged in there, and to say when they
+// did in the ID token's auth_time.
+// 和 AuthCodeURL 一样，但是要求身份提供方让用户重新登录（即使用户在那里仍然是登录状态），
+// 并在 ID token 的 auth_time 中说明登录的时间
+func (p *Provider) ReauthCodeURL(state, nonce, verifier string) string {
+	return p.authCodeURL(state, nonce, verifier, url.Values{"prompt": {"login"}, "max_age": {"0"}})
+}
+
+func (p *Provider) authCodeURL(state, nonce, verifier string, extra url.Values) string {
+	v := url.Values{}
+	v.Set("response_type", "code")
+	v.Set("client_id", p.config.ClientID)
+	v.Set("redirect_uri", p.config.RedirectURL)
+	v.Set("scope", strings.Join(p.config.Scopes, " "))
+	v.Set("state", state)
+	v.Set("nonce", nonce)
+	v.Set("code_challenge", Challenge(verifier))
+	v.Set("code_challenge_method", "S256")
+	for k, values := range extra {
+		v[k] = values
+	}
+
+	sep := "?"
+	if strings.Contains(p.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return p.AuthorizationEndpoint + sep + v.Encode()
+}
+
+// Exchange trades an authorization code for tokens at the token endpoint and
+// returns the raw ID token.
+// 在 token 端点用授权码换取 token，返回原始的 ID token
+func (p *Provider) Exchange(ctx context.Context, code, verifier string) (string, error) {
+	v := url.Values{}
+	v.Set("grant_type", "authorization_code")
+	v.Set("code", code)
+	v.Set("redirect_uri", p.config.RedirectURL)
+	v.Set("code_verifier", verifier)
+	if p.config.ClientSecret == "" {
+		v.Set("client_id", p.config.ClientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.TokenEndpoint, strings.NewReader(v.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	err = json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrExchange, resp.Status)
+	}
+
+	if resp.StatusCode != http.StatusOK || body.Error != "" {
+		return "", fmt.Errorf("%w: %s %s", ErrExchange, body.Error, body.ErrorDescription)
+	}
+	if body.IDToken == "" {
+		return "", fmt.Errorf("%w: no id_token in response", ErrExchange)
+	}
+
+	return body.IDToken, nil
+}
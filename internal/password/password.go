@@ -0,0 +1,215 @@
+// Package password hashes and verifies user passwords. It understands both
+// bcrypt and Argon2id hashes, so the algorithm or its cost can be changed
+// without locking anyone out: old hashes keep working and are upgraded the
+// next time the user logs in.
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Supported algorithms.
+// 支持的哈希算法
+const (
+	Bcrypt   = "bcrypt"
+	Argon2id = "argon2id"
+)
+
+var (
+	// ErrUnknownHash is returned when a stored hash isn't in a format we
+	// recognise.
+	ErrUnknownHash = errors.New("password: unknown hash format")
+
+	// ErrInvalidPolicy is returned by Policy.Validate.
+	ErrInvalidPolicy = errors.New("password: invalid policy")
+)
+
+// Argon2Params are the tuning parameters for Argon2id. Memory is in KiB.
+// Argon2id 的参数，Memory 的单位是 KiB
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// Policy says how new passwords should be hashed.
+// Policy 决定新密码使用什么算法和参数进行哈希
+type Policy struct {
+	Algorithm  string
+	BcryptCost int
+	Argon2     Argon2Params
+}
+
+// DefaultPolicy matches what the application has always done: bcrypt with a
+// cost of 12. The Argon2id parameters follow the OWASP recommendations, for
+// when Argon2id is switched on.
+// 默认策略和之前保持一致：bcrypt，cost 为 12。Argon2id 参数遵循 OWASP 的建议
+func DefaultPolicy() *Policy {
+	return &Policy{
+		Algorithm:  Bcrypt,
+		BcryptCost: 12,
+		Argon2: Argon2Params{
+			Memory:      64 * 1024,
+			Iterations:  3,
+			Parallelism: 2,
+			SaltLength:  16,
+			KeyLength:   32,
+		},
+	}
+}
+
+// Validate checks the policy is usable, so that mistakes in configuration
+// are caught at startup rather than the first time someone signs up.
+// 检查策略是否可用，让配置错误在启动时就暴露出来
+func (p *Policy) Validate() error {
+	switch p.Algorithm {
+	case Bcrypt:
+		if p.BcryptCost < bcrypt.MinCost || p.BcryptCost > bcrypt.MaxCost {
+			return fmt.Errorf("%w: bcrypt cost must be between %d and %d", ErrInvalidPolicy, bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	case Argon2id:
+		a := p.Argon2
+		if a.Memory < 8*uint32(a.Parallelism) || a.Iterations < 1 || a.Parallelism < 1 {
+			return fmt.Errorf("%w: argon2id needs at least 1 iteration, 1 thread and 8 KiB of memory per thread", ErrInvalidPolicy)
+		}
+		if a.SaltLength < 8 || a.KeyLength < 16 {
+			return fmt.Errorf("%w: argon2id salt must be at least 8 bytes and key at least 16 bytes", ErrInvalidPolicy)
+		}
+	default:
+		return fmt.Errorf("%w: unknown algorithm %q", ErrInvalidPolicy, p.Algorithm)
+	}
+	return nil
+}
+
+// Hash hashes a plain-text password with the policy's algorithm.
+// 使用策略指定的算法对明文密码进行哈希
+func (p *Policy) Hash(password string) (string, error) {
+	switch p.Algorithm {
+	case Argon2id:
+		return hashArgon2id(password, p.Argon2)
+	default:
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), p.BcryptCost)
+		if err != nil {
+			return "", err
+		}
+		return string(hash), nil
+	}
+}
+
+// Verify reports whether password matches the stored hash, whichever of the
+// supported algorithms it was created with.
+// 校验密码和哈希值是否匹配，支持任意一种已知算法生成的哈希
+func Verify(password, hash string) (bool, error) {
+	switch {
+	case isBcrypt(hash):
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if err != nil {
+			if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+				return false, nil
+			}
+			return false, err
+		}
+		return true, nil
+	case strings.HasPrefix(hash, "$argon2id$"):
+		params, salt, key, err := decodeArgon2id(hash)
+		if err != nil {
+			return false, err
+		}
+		other := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+		return subtle.ConstantTimeCompare(key, other) == 1, nil
+	default:
+		return false, ErrUnknownHash
+	}
+}
+
+// NeedsRehash reports whether a stored hash is weaker than (or just
+// different from) what the policy asks for, and so should be replaced the
+// next time the plain-text password is available.
+// 判断已保存的哈希是否弱于（或者不同于）当前策略，如果是，下次拿到明文密码时应该重新哈希
+func (p *Policy) NeedsRehash(hash string) bool {
+	switch p.Algorithm {
+	case Bcrypt:
+		if !isBcrypt(hash) {
+			return true
+		}
+		cost, err := bcrypt.Cost([]byte(hash))
+		return err != nil || cost < p.BcryptCost
+	case Argon2id:
+		params, _, _, err := decodeArgon2id(hash)
+		if err != nil {
+			return true
+		}
+		return params.Memory < p.Argon2.Memory ||
+			params.Iterations < p.Argon2.Iterations ||
+			params.Parallelism < p.Argon2.Parallelism ||
+			params.KeyLength < p.Argon2.KeyLength
+	}
+	return false
+}
+
+func isBcrypt(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+// hashArgon2id returns a hash in the PHC string format used by the reference
+// implementation, e.g. $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>.
+// 返回 PHC 格式的哈希字符串，和参考实现的格式一致
+func hashArgon2id(password string, a Argon2Params) (string, error) {
+	salt := make([]byte, a.SaltLength)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, a.Iterations, a.Memory, a.Parallelism, a.KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, a.Memory, a.Iterations, a.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func decodeArgon2id(hash string) (Argon2Params, []byte, []byte, error) {
+	var a Argon2Params
+
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return a, nil, nil, ErrUnknownHash
+	}
+
+	var version int
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil || version != argon2.Version {
+		return a, nil, nil, ErrUnknownHash
+	}
+
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &a.Memory, &a.Iterations, &a.Parallelism)
+	if err != nil {
+		return a, nil, nil, ErrUnknownHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return a, nil, nil, ErrUnknownHash
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return a, nil, nil, ErrUnknownHash
+	}
+
+	a.SaltLength = uint32(len(salt))
+	a.KeyLength = uint32(len(key))
+
+	return a, salt, key, nil
+}
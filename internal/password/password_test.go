@@ -0,0 +1,141 @@
+package password
+
+import (
+	"errors"
+	"testing"
+)
+
+// Cheap policies, so that the tests don't spend seconds hashing.
+func bcryptPolicy(cost int) *Policy {
+	return &Policy{Algorithm: Bcrypt, BcryptCost: cost}
+}
+
+func argon2Policy(memory, iterations uint32) *Policy {
+	return &Policy{
+		Algorithm: Argon2id,
+		Argon2:    Argon2Params{Memory: memory, Iterations: iterations, Parallelism: 1, SaltLength: 16, KeyLength: 32},
+	}
+}
+
+func TestHashAndVerify(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy *Policy
+	}{
+		{"Bcrypt", bcryptPolicy(4)},
+		{"Argon2id", argon2Policy(64, 1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hash, err := tt.policy.Hash("correct horse battery staple")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ok, err := Verify("correct horse battery staple", hash)
+			if err != nil || !ok {
+				t.Errorf("right password: got (%t, %v); want (true, nil)", ok, err)
+			}
+
+			ok, err = Verify("Correct horse battery staple", hash)
+			if err != nil || ok {
+				t.Errorf("wrong password: got (%t, %v); want (false, nil)", ok, err)
+			}
+
+			again, err := tt.policy.Hash("correct horse battery staple")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if again == hash {
+				t.Error("two hashes of the same password are identical; want different salts")
+			}
+		})
+	}
+}
+
+func TestVerifyUnknownHash(t *testing.T) {
+	tests := []string{
+		"",
+		"plain text",
+		"$argon2i$v=19$m=64,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=18$m=64,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=64,t=1$c2FsdA$a2V5",
+	}
+
+	for _, hash := range tests {
+		_, err := Verify("password", hash)
+		if !errors.Is(err, ErrUnknownHash) {
+			t.Errorf("Verify(%q): got error %v; want ErrUnknownHash", hash, err)
+		}
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	hash := func(p *Policy) string {
+		h, err := p.Hash("password")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+
+	bcrypt4 := hash(bcryptPolicy(4))
+	bcrypt5 := hash(bcryptPolicy(5))
+	argon2Small := hash(argon2Policy(64, 1))
+	argon2Large := hash(argon2Policy(128, 2))
+
+	tests := []struct {
+		name   string
+		policy *Policy
+		hash   string
+		want   bool
+	}{
+		{"Bcrypt same cost", bcryptPolicy(4), bcrypt4, false},
+		{"Bcrypt higher cost", bcryptPolicy(4), bcrypt5, false},
+		{"Bcrypt lower cost", bcryptPolicy(5), bcrypt4, true},
+		{"Bcrypt policy, Argon2id hash", bcryptPolicy(4), argon2Small, true},
+		{"Argon2id same parameters", argon2Policy(64, 1), argon2Small, false},
+		{"Argon2id stronger hash", argon2Policy(64, 1), argon2Large, false},
+		{"Argon2id weaker hash", argon2Policy(128, 2), argon2Small, true},
+		{"Argon2id policy, bcrypt hash", argon2Policy(64, 1), bcrypt4, true},
+		{"Unknown hash", bcryptPolicy(4), "plain text", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.policy.NeedsRehash(tt.hash)
+			if got != tt.want {
+				t.Errorf("got %t; want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy *Policy
+		valid  bool
+	}{
+		{"Default", DefaultPolicy(), true},
+		{"Bcrypt cost too low", bcryptPolicy(3), false},
+		{"Bcrypt cost too high", bcryptPolicy(32), false},
+		{"Argon2id", argon2Policy(64, 1), true},
+		{"Argon2id no iterations", argon2Policy(64, 0), false},
+		{"Argon2id too little memory", argon2Policy(4, 1), false},
+		{"Unknown algorithm", &Policy{Algorithm: "md5"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Validate()
+			if tt.valid && err != nil {
+				t.Errorf("got error %v; want nil", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidPolicy) {
+				t.Errorf("got error %v; want ErrInvalidPolicy", err)
+			}
+		})
+	}
+}
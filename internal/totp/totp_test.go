@@ -0,0 +1,94 @@
+package totp
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 key from RFC 6238 appendix B, "12345678901234567890",
+// base32 encoded.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCode(t *testing.T) {
+	// The RFC's codes have 8 digits; ours are their last 6.
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		t.Run(time.Unix(tt.unix, 0).UTC().Format(time.RFC3339), func(t *testing.T) {
+			code, err := Code(rfcSecret, time.Unix(tt.unix, 0))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if code != tt.want {
+				t.Errorf("got %q; want %q", code, tt.want)
+			}
+		})
+	}
+}
+
+func TestCodeInvalidSecret(t *testing.T) {
+	for _, secret := range []string{"", "not base32!", "="} {
+		_, err := Code(secret, time.Now())
+		if !errors.Is(err, ErrInvalidSecret) {
+			t.Errorf("Code(%q): got error %v; want ErrInvalidSecret", secret, err)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+
+	tests := []struct {
+		name     string
+		secret   string
+		code     string
+		skew     int
+		wantStep int64
+		wantOK   bool
+	}{
+		{name: "Current step", secret: rfcSecret, code: "050471", skew: 1, wantStep: 37037037, wantOK: true},
+		{name: "With spaces", secret: rfcSecret, code: "050 471", skew: 1, wantStep: 37037037, wantOK: true},
+		{name: "Forgiving secret", secret: "gezd gnbv gy3t qojq gezd gnbv gy3t qojq====", code: "050471", skew: 0, wantStep: 37037037, wantOK: true},
+		{name: "Previous step within skew", secret: rfcSecret, code: "081804", skew: 1, wantStep: 37037036, wantOK: true},
+		{name: "Previous step without skew", secret: rfcSecret, code: "081804", skew: 0},
+		{name: "Wrong code", secret: rfcSecret, code: "123456", skew: 1},
+		{name: "Too short", secret: rfcSecret, code: "50471", skew: 1},
+		{name: "Too long", secret: rfcSecret, code: "0504710", skew: 1},
+		{name: "Invalid secret", secret: "not base32!", code: "050471", skew: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := Validate(tt.secret, tt.code, now, tt.skew)
+			if ok != tt.wantOK || step != tt.wantStep {
+				t.Errorf("got (%d, %t); want (%d, %t)", step, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := decodeSecret(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(key) != secretSize {
+		t.Errorf("got %d byte key; want %d", len(key), secretSize)
+	}
+}
@@ -0,0 +1,120 @@
+package validator
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// BreachedPasswords is a set of SHA-1 hashes of passwords known to have
+// appeared in data breaches. It's loaded from files in the same format as the
+// Have I Been Pwned range API (k-anonymity): either a directory of files named
+// after a 5 character hash prefix, each holding "SUFFIX:COUNT" lines, or a
+// single file of full "HASH:COUNT" lines.
+// 已经在数据泄露中出现过的密码的 SHA-1 哈希集合，文件格式与 Have I Been Pwned 的 range API（k-匿名）相同：
+// 可以是以 5 位哈希前缀命名的文件目录（每行 "后缀:次数"），也可以是每行 "完整哈希:次数" 的单个文件
+type BreachedPasswords struct {
+	hashes map[[sha1.Size]byte]struct{}
+}
+
+// LoadBreachedPasswords reads a breached password list from path, which may
+// be a directory of range files or a single file.
+// 从 path 读取泄露密码列表，path 可以是 range 文件目录，也可以是单个文件
+func LoadBreachedPasswords(path string) (*BreachedPasswords, error) {
+	b := &BreachedPasswords{hashes: make(map[[sha1.Size]byte]struct{})}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		return b, b.loadFile(path, "")
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		// Range files are named after their prefix, optionally with an
+		// extension (e.g. "5BAA6" or "5BAA6.txt").
+		// range 文件以前缀命名，可以带扩展名
+		prefix := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		if len(prefix) != 5 || !isHex(prefix) {
+			continue
+		}
+
+		err = b.loadFile(filepath.Join(path, entry.Name()), prefix)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return b, nil
+}
+
+func (b *BreachedPasswords) loadFile(name, prefix string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		// The count after the colon isn't needed, any appearance is enough.
+		// 冒号后面的次数不需要，出现过一次就足够了
+		suffix, _, _ := strings.Cut(text, ":")
+
+		var hash [sha1.Size]byte
+		n, err := hex.Decode(hash[:], []byte(prefix+suffix))
+		if err != nil || n != sha1.Size {
+			return fmt.Errorf("%s:%d: invalid hash %q", name, line, prefix+suffix)
+		}
+		b.hashes[hash] = struct{}{}
+	}
+
+	return scanner.Err()
+}
+
+func isHex(s string) bool {
+	_, err := hex.DecodeString(s + strings.Repeat("0", len(s)%2))
+	return err == nil
+}
+
+// Len returns the number of hashes in the list.
+func (b *BreachedPasswords) Len() int {
+	return len(b.hashes)
+}
+
+// Contains reports whether the password is in the list.
+// 判断密码是否在列表中
+func (b *BreachedPasswords) Contains(password string) bool {
+	_, ok := b.hashes[sha1.Sum([]byte(password))]
+	return ok
+}
+
+// NotBreached() returns true if a value isn't in the breached password list.
+// A nil list means no list was configured, so every value passes.
+// 如果值不在泄露密码列表中返回 true，列表为 nil 表示没有配置，所有值都通过
+func NotBreached(value string, list *BreachedPasswords) bool {
+	return list == nil || !list.Contains(value)
+}
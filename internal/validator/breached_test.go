@@ -0,0 +1,82 @@
+package validator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// The SHA-1 hash of "password" is 5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8.
+
+func TestLoadBreachedPasswords(t *testing.T) {
+	tests := []struct {
+		name  string
+		file  string            // contents of a single file
+		files map[string]string // or a directory of range files
+		err   bool
+	}{
+		{
+			name: "Single file",
+			file: "5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:3861493\n",
+		},
+		{
+			name: "Range directory",
+			files: map[string]string{
+				"5BAA6.txt": "1E4C9B93F3F0682250B6CF8331B7EE68FD8:3861493\r\n",
+				"README":    "not a range file",
+			},
+		},
+		{
+			name:  "Lower case",
+			files: map[string]string{"5baa6": "1e4c9b93f3f0682250b6cf8331b7ee68fd8:1\n"},
+		},
+		{
+			name: "Bad hash",
+			file: "5BAA61E4C9B93F3F0682250B6CF8331B7EE68F:1\n",
+			err:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := t.TempDir()
+			if tt.files == nil {
+				path = filepath.Join(path, "passwords.txt")
+				tt.files = map[string]string{"": tt.file}
+			}
+			for name, contents := range tt.files {
+				err := os.WriteFile(filepath.Join(path, name), []byte(contents), 0o600)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			list, err := LoadBreachedPasswords(path)
+			if tt.err {
+				if err == nil {
+					t.Fatal("got no error; want one")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if list.Len() != 1 {
+				t.Errorf("got %d hashes; want 1", list.Len())
+			}
+			if NotBreached("password", list) {
+				t.Error(`"password" isn't in the list`)
+			}
+			if !NotBreached("correct horse battery staple", list) {
+				t.Error(`"correct horse battery staple" is in the list`)
+			}
+		})
+	}
+}
+
+func TestNotBreachedWithoutList(t *testing.T) {
+	if !NotBreached("password", nil) {
+		t.Error("got false without a list; want true")
+	}
+}
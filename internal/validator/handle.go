@@ -0,0 +1,39 @@
+package validator
+
+import (
+	"regexp"
+	"strings"
+)
+
+// HandleRX matches a handle: 3 to 30 lower case letters, digits and
+// underscores, starting with a letter. Handles are lower cased before they're
+// checked or stored, so they're case insensitive.
+// handle 由 3 到 30 个小写字母、数字和下划线组成，以字母开头。检查和保存前会转换成小写，所以不区分大小写
+var HandleRX = regexp.MustCompile(`^[a-z][a-z0-9_]{2,29}$`)
+
+// reservedHandles can't be taken by anyone, because they'd look official or
+// clash with our own URLs.
+// 保留的 handle，因为它们看起来像官方账号或者会和我们自己的 URL 冲突
+var reservedHandles = map[string]bool{
+	"about": true, "account": true, "admin": true, "administrator": true,
+	"api": true, "help": true, "info": true, "login": true, "logout": true,
+	"mail": true, "me": true, "moderator": true, "null": true, "official": true,
+	"postmaster": true, "root": true, "security": true, "settings": true,
+	"signup": true, "snippet": true, "snippetbox": true, "snippets": true,
+	"static": true, "staff": true, "support": true, "system": true,
+	"undefined": true, "user": true, "users": true, "webmaster": true,
+	"www": true,
+}
+
+// NormalizeHandle trims a handle and lower cases it, and drops a leading "@"
+// which people often type.
+// 去掉 handle 两边的空白和开头的 "@"，并转换成小写
+func NormalizeHandle(value string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(value), "@"))
+}
+
+// NotReservedHandle returns true if a handle isn't one of the reserved words.
+// 如果 handle 不是保留字，返回 true
+func NotReservedHandle(value string) bool {
+	return !reservedHandles[value]
+}
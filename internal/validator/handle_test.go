@@ -0,0 +1,43 @@
+package validator
+
+import "testing"
+
+func TestHandle(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string // after NormalizeHandle
+		valid bool
+	}{
+		{name: "Simple", input: "alice", want: "alice", valid: true},
+		{name: "Digits and underscores", input: "alice_99", want: "alice_99", valid: true},
+		{name: "Upper case", input: "Alice", want: "alice", valid: true},
+		{name: "At sign and spaces", input: "  @alice ", want: "alice", valid: true},
+		{name: "Shortest", input: "abc", want: "abc", valid: true},
+		{name: "Longest", input: "a23456789012345678901234567890", want: "a23456789012345678901234567890", valid: true},
+		{name: "Too short", input: "ab", want: "ab"},
+		{name: "Too long", input: "a234567890123456789012345678901", want: "a234567890123456789012345678901"},
+		{name: "Starts with a digit", input: "1alice", want: "1alice"},
+		{name: "Starts with an underscore", input: "_alice", want: "_alice"},
+		{name: "Hyphen", input: "alice-smith", want: "alice-smith"},
+		{name: "Dot", input: "alice.smith", want: "alice.smith"},
+		{name: "Non-ASCII", input: "alicé", want: "alicé"},
+		{name: "Two at signs", input: "@@alice", want: "@alice"},
+		{name: "Reserved", input: "admin", want: "admin"},
+		{name: "Reserved in upper case", input: "@Admin", want: "admin"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handle := NormalizeHandle(tt.input)
+			if handle != tt.want {
+				t.Errorf("got normalized %q; want %q", handle, tt.want)
+			}
+
+			valid := Matches(handle, HandleRX) && NotReservedHandle(handle)
+			if valid != tt.valid {
+				t.Errorf("got valid %t; want %t", valid, tt.valid)
+			}
+		})
+	}
+}
@@ -0,0 +1,700 @@
+package validator
+
+import (
+	"bufio"
+	"embed"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// The strength estimator below follows the approach of Dropbox's zxcvbn: it
+// finds the parts of a password an attacker would guess first (common
+// passwords, dictionary words, sequences, repeats, keyboard rows and dates),
+// works out the cheapest way to cover the whole password with those parts
+// and brute force, and scores the password on that number of guesses.
+// 下面的密码强度估算参考了 Dropbox 的 zxcvbn：找出攻击者会优先猜测的部分（常见密码、字典单词、序列、重复、
+// 键盘行和日期），计算用这些部分和暴力破解覆盖整个密码的最小猜测次数，再根据猜测次数给密码打分
+
+//go:embed "data"
+var dataFS embed.FS
+
+// Define the kinds of pattern the estimator recognises.
+const (
+	patternDictionary = "dictionary"
+	patternUserInput  = "user-input"
+	patternSequence   = "sequence"
+	patternRepeat     = "repeat"
+	patternSpatial    = "spatial"
+	patternDate       = "date"
+	patternBruteforce = "bruteforce"
+)
+
+// maxStrengthInput is the number of characters that are analysed. Anything
+// after this is treated as brute force, which keeps the estimator fast.
+// 只分析前 maxStrengthInput 个字符，之后的部分按暴力破解计算，保证估算速度
+const maxStrengthInput = 64
+
+var (
+	commonPasswords = loadRanked("data/passwords.txt")
+	commonWords     = loadRanked("data/words.txt")
+
+	// keyboardRows are straight runs of keys on a QWERTY keyboard.
+	// QWERTY 键盘上的连续按键
+	keyboardRows = []string{
+		"`1234567890-=",
+		"qwertyuiop[]\\",
+		"asdfghjkl;'",
+		"zxcvbnm,./",
+		"1qaz2wsx3edc4rfv5tgb6yhn7ujm8ik,9ol.0p;/",
+	}
+
+	leetSubstitutions = map[rune]rune{
+		'4': 'a', '@': 'a', '8': 'b', '(': 'c', '3': 'e', '6': 'g', '1': 'i',
+		'!': 'i', '|': 'l', '0': 'o', '$': 's', '5': 's', '7': 't', '+': 't', '2': 'z',
+	}
+)
+
+// loadRanked reads an embedded word list, ranking each word by its line
+// number (so the most common words are the cheapest to guess).
+// 读取内嵌的单词列表，以行号作为排名（越常见越容易被猜到）
+func loadRanked(name string) map[string]int {
+	ranked := map[string]int{}
+
+	f, err := dataFS.Open(name)
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for rank := 1; scanner.Scan(); {
+		word := strings.TrimSpace(scanner.Text())
+		if word == "" {
+			continue
+		}
+		if _, exists := ranked[word]; !exists {
+			ranked[word] = rank
+			rank++
+		}
+	}
+
+	return ranked
+}
+
+// match is a part of the password, from rune i to rune j inclusive, which
+// could be guessed in the given number of guesses.
+type match struct {
+	i, j    int
+	pattern string
+	token   string
+	guesses float64
+	// common is set for dictionary matches against the common password list,
+	// and leet when the word was found by undoing l33t substitutions, so that
+	// feedback can say so.
+	common bool
+	leet   bool
+}
+
+// Strength is the result of estimating how hard a password is to guess.
+// Score runs from 0 (too guessable) to 4 (very unguessable), like zxcvbn.
+// 密码强度估算结果，Score 从 0（非常容易猜到）到 4（非常难猜到），和 zxcvbn 一致
+type Strength struct {
+	Score       int
+	Guesses     float64
+	Warning     string
+	Suggestions []string
+}
+
+// Feedback returns the warning and suggestions as a single sentence which
+// can be shown next to the password field.
+// 把警告和建议合并成一句话，显示在密码输入框旁边
+func (s Strength) Feedback() string {
+	parts := []string{}
+	if s.Warning != "" {
+		parts = append(parts, s.Warning+".")
+	}
+	for _, suggestion := range s.Suggestions {
+		parts = append(parts, suggestion+".")
+	}
+	return strings.Join(parts, " ")
+}
+
+// PasswordStrength estimates the strength of a password. Any userInputs
+// (like the user's name and email address) are treated as the easiest
+// words of all to guess.
+// 估算密码强度，userInputs（比如用户名和邮箱）会被视为最容易猜到的单词
+func PasswordStrength(password string, userInputs ...string) Strength {
+	runes := []rune(password)
+	tail := 0
+	if len(runes) > maxStrengthInput {
+		tail = len(runes) - maxStrengthInput
+		runes = runes[:maxStrengthInput]
+	}
+
+	inputs := map[string]int{}
+	for _, input := range userInputs {
+		input = strings.ToLower(input)
+		// Email addresses are mostly guessed by their local part.
+		// 邮箱地址通常按 @ 前面的部分被猜测
+		if local, _, found := strings.Cut(input, "@"); found {
+			inputs[local] = 1
+		}
+		for _, field := range strings.FieldsFunc(input, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+			if len([]rune(field)) >= 3 {
+				inputs[field] = 1
+			}
+		}
+	}
+
+	matches := []match{}
+	matches = append(matches, dictionaryMatches(runes, inputs)...)
+	matches = append(matches, sequenceMatches(runes)...)
+	matches = append(matches, repeatMatches(runes)...)
+	matches = append(matches, spatialMatches(runes)...)
+	matches = append(matches, dateMatches(runes)...)
+
+	guesses, sequence := mostGuessableSequence(runes, matches)
+	guesses *= math.Pow(10, float64(tail))
+
+	s := Strength{Guesses: guesses, Score: score(guesses)}
+	s.Warning, s.Suggestions = feedback(s.Score, sequence)
+	return s
+}
+
+func score(guesses float64) int {
+	switch {
+	case guesses < 1e3+5:
+		return 0
+	case guesses < 1e6+5:
+		return 1
+	case guesses < 1e8+5:
+		return 2
+	case guesses < 1e10+5:
+		return 3
+	default:
+		return 4
+	}
+}
+
+// minGuesses stops any single match being counted as nearly free.
+func minGuesses(length int) float64 {
+	if length == 1 {
+		return 10
+	}
+	return 50
+}
+
+// mostGuessableSequence finds the cover of the password by matches and
+// brute force which needs the fewest guesses. Like zxcvbn, a sequence of l
+// parts costs l! times the product of its parts, because an attacker also has
+// to guess the order they come in.
+// 找出用匹配和暴力破解覆盖整个密码所需猜测次数最少的组合。和 zxcvbn 一样，l 个部分的组合需要乘以 l!，
+// 因为攻击者还需要猜测它们的顺序
+func mostGuessableSequence(runes []rune, matches []match) (float64, []match) {
+	n := len(runes)
+	if n == 0 {
+		return 1, nil
+	}
+
+	// Brute force can cover any substring, 10 guesses per character.
+	// 暴力破解可以覆盖任意子串，每个字符 10 次猜测
+	for i := 0; i < n; i++ {
+		for j := i; j < n; j++ {
+			matches = append(matches, match{
+				i: i, j: j,
+				pattern: patternBruteforce,
+				token:   string(runes[i : j+1]),
+				guesses: math.Pow(10, float64(j-i+1)),
+			})
+		}
+	}
+
+	byEnd := make([][]int, n)
+	for k, m := range matches {
+		if m.pattern != patternBruteforce {
+			m.guesses = math.Max(m.guesses, minGuesses(m.j-m.i+1))
+			matches[k] = m
+		}
+		byEnd[m.j] = append(byEnd[m.j], k)
+	}
+
+	// best[k][l] is the fewest guesses to cover the first k runes with l
+	// matches, and prev records which match got there.
+	// best[k][l] 表示用 l 个匹配覆盖前 k 个字符所需的最少猜测次数，prev 记录最后一个匹配
+	best := make([][]float64, n+1)
+	prev := make([][]int, n+1)
+	for k := range best {
+		best[k] = make([]float64, n+1)
+		prev[k] = make([]int, n+1)
+		for l := range best[k] {
+			best[k][l] = math.Inf(1)
+			prev[k][l] = -1
+		}
+	}
+	best[0][0] = 1
+
+	for j := 0; j < n; j++ {
+		for _, idx := range byEnd[j] {
+			m := matches[idx]
+			for l := 0; l < n; l++ {
+				if math.IsInf(best[m.i][l], 1) {
+					continue
+				}
+				// Two brute force runs next to each other are really one run.
+				// 相邻的两段暴力破解实际上是一段
+				if m.pattern == patternBruteforce && prev[m.i][l] >= 0 && matches[prev[m.i][l]].pattern == patternBruteforce {
+					continue
+				}
+				g := best[m.i][l] * m.guesses
+				if g < best[j+1][l+1] {
+					best[j+1][l+1] = g
+					prev[j+1][l+1] = idx
+				}
+			}
+		}
+	}
+
+	bestL := 1
+	bestGuesses := math.Inf(1)
+	for l := 1; l <= n; l++ {
+		g := factorial(l) * best[n][l]
+		if g < bestGuesses {
+			bestGuesses = g
+			bestL = l
+		}
+	}
+
+	sequence := make([]match, 0, bestL)
+	for k, l := n, bestL; k > 0 && l > 0; l-- {
+		m := matches[prev[k][l]]
+		sequence = append([]match{m}, sequence...)
+		k = m.i
+	}
+
+	return bestGuesses, sequence
+}
+
+func factorial(n int) float64 {
+	f := 1.0
+	for i := 2; i <= n; i++ {
+		f *= float64(i)
+	}
+	return f
+}
+
+// uppercaseVariations is how many more guesses capital letters add to a
+// word. Capitalising the first letter or the whole word is so common it only
+// doubles the guesses.
+// 大写字母带来的额外猜测次数，首字母大写或者全部大写非常常见，只让猜测次数翻倍
+func uppercaseVariations(token string) float64 {
+	upper, lower := 0, 0
+	for _, r := range token {
+		if unicode.IsUpper(r) {
+			upper++
+		} else if unicode.IsLower(r) {
+			lower++
+		}
+	}
+
+	if upper == 0 {
+		return 1
+	}
+
+	first := []rune(token)[0]
+	if lower == 0 || (upper == 1 && unicode.IsUpper(first)) {
+		return 2
+	}
+
+	// Otherwise count the ways of choosing which letters are capitals.
+	// 否则计算选择哪些字母大写的组合数
+	variations := 0.0
+	for k := 1; k <= upper && k <= lower; k++ {
+		variations += binomial(upper+lower, k)
+	}
+	return math.Max(variations, 2)
+}
+
+func binomial(n, k int) float64 {
+	if k > n {
+		return 0
+	}
+	r := 1.0
+	for d := 1; d <= k; d++ {
+		r *= float64(n - k + d)
+		r /= float64(d)
+	}
+	return r
+}
+
+func dictionaryMatches(runes []rune, inputs map[string]int) []match {
+	matches := []match{}
+
+	lookup := func(word string) (int, string, bool) {
+		if rank, ok := inputs[word]; ok {
+			return rank, patternUserInput, true
+		}
+		if rank, ok := commonPasswords[word]; ok {
+			return rank, patternDictionary, true
+		}
+		if rank, ok := commonWords[word]; ok {
+			// Words rank after all the common passwords.
+			// 普通单词排在所有常见密码之后
+			return rank + len(commonPasswords), patternDictionary, true
+		}
+		return 0, "", false
+	}
+
+	for i := 0; i < len(runes); i++ {
+		for j := i + 2; j < len(runes) && j-i < 20; j++ {
+			token := string(runes[i : j+1])
+			lower := strings.ToLower(token)
+
+			type candidate struct {
+				word       string
+				multiplier float64
+				leet       bool
+			}
+
+			candidates := []candidate{
+				{lower, 1, false},
+				{reverse(lower), 2, false},
+			}
+			if unleet, n := unLeet(lower); n > 0 {
+				candidates = append(candidates, candidate{unleet, math.Pow(2, float64(n)), true})
+			}
+
+			for _, c := range candidates {
+				rank, pattern, ok := lookup(c.word)
+				if !ok {
+					continue
+				}
+				matches = append(matches, match{
+					i: i, j: j,
+					pattern: pattern,
+					token:   token,
+					guesses: float64(rank) * uppercaseVariations(token) * c.multiplier,
+					common:  pattern == patternDictionary && rank <= len(commonPasswords),
+					leet:    c.leet,
+				})
+				break
+			}
+		}
+	}
+
+	return matches
+}
+
+func reverse(s string) string {
+	r := []rune(s)
+	for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+		r[i], r[j] = r[j], r[i]
+	}
+	return string(r)
+}
+
+// unLeet undoes common l33t substitutions and returns the result along with
+// how many characters were substituted.
+func unLeet(s string) (string, int) {
+	n := 0
+	out := []rune(s)
+	for k, r := range out {
+		if sub, ok := leetSubstitutions[r]; ok {
+			out[k] = sub
+			n++
+		}
+	}
+	return string(out), n
+}
+
+func sequenceMatches(runes []rune) []match {
+	matches := []match{}
+
+	for i := 0; i < len(runes)-2; {
+		delta := runes[i+1] - runes[i]
+		if delta != 1 && delta != -1 || !sameClass(runes[i], runes[i+1]) {
+			i++
+			continue
+		}
+
+		j := i + 1
+		for j+1 < len(runes) && runes[j+1]-runes[j] == delta && sameClass(runes[j], runes[j+1]) {
+			j++
+		}
+
+		if j-i+1 >= 3 {
+			first := unicode.ToLower(runes[i])
+			base := 26.0
+			switch {
+			case strings.ContainsRune("az19", first):
+				base = 4
+			case unicode.IsDigit(first):
+				base = 10
+			}
+			if delta < 0 {
+				base *= 2
+			}
+			matches = append(matches, match{
+				i: i, j: j,
+				pattern: patternSequence,
+				token:   string(runes[i : j+1]),
+				guesses: base * float64(j-i+1),
+			})
+		}
+		i = j
+	}
+
+	return matches
+}
+
+func sameClass(a, b rune) bool {
+	switch {
+	case unicode.IsDigit(a):
+		return unicode.IsDigit(b)
+	case unicode.IsLower(a):
+		return unicode.IsLower(b)
+	case unicode.IsUpper(a):
+		return unicode.IsUpper(b)
+	}
+	return false
+}
+
+func repeatMatches(runes []rune) []match {
+	matches := []match{}
+
+	for i := 0; i < len(runes); i++ {
+		// Try every base length and keep the repeat which covers the most.
+		// 尝试所有可能的基础长度，保留覆盖最长的重复
+		for size := 1; i+2*size <= len(runes); size++ {
+			base := runes[i : i+size]
+			count := 1
+			for k := i + size; k+size <= len(runes) && string(runes[k:k+size]) == string(base); k += size {
+				count++
+			}
+			if count < 2 || (size == 1 && count < 3) {
+				continue
+			}
+
+			baseGuesses := math.Pow(10, float64(size))
+			if size == 1 {
+				baseGuesses = cardinality(base[0])
+			}
+
+			matches = append(matches, match{
+				i: i, j: i + size*count - 1,
+				pattern: patternRepeat,
+				token:   string(runes[i : i+size*count]),
+				guesses: baseGuesses * float64(count),
+			})
+		}
+	}
+
+	return matches
+}
+
+func cardinality(r rune) float64 {
+	switch {
+	case unicode.IsDigit(r):
+		return 10
+	case unicode.IsLower(r), unicode.IsUpper(r):
+		return 26
+	}
+	return 33
+}
+
+func spatialMatches(runes []rune) []match {
+	matches := []match{}
+	lower := []rune(strings.ToLower(string(runes)))
+
+	for _, row := range keyboardRows {
+		for _, line := range []string{row, reverse(row)} {
+			for i := 0; i < len(lower); i++ {
+				for j := i + 3; j < len(lower); j++ {
+					token := string(lower[i : j+1])
+					if !strings.Contains(line, token) {
+						break
+					}
+					// Roughly: any of ~47 starting keys, then one of a few
+					// directions for each following key.
+					// 粗略估算：起始键约有 47 个，之后每个键只有少数几个方向可选
+					matches = append(matches, match{
+						i: i, j: j,
+						pattern: patternSpatial,
+						token:   string(runes[i : j+1]),
+						guesses: 47 * 4 * float64(j-i+1) * uppercaseVariations(string(runes[i:j+1])),
+					})
+				}
+			}
+		}
+	}
+
+	return matches
+}
+
+func dateMatches(runes []rune) []match {
+	matches := []match{}
+	thisYear := time.Now().Year()
+
+	yearSpace := func(year int) float64 {
+		return math.Max(math.Abs(float64(year-thisYear)), 20)
+	}
+
+	for i := 0; i < len(runes); i++ {
+		for j := i + 3; j < len(runes) && j-i < 10; j++ {
+			token := string(runes[i : j+1])
+
+			// A year on its own.
+			// 单独的年份
+			if len(token) == 4 && allDigits(token) {
+				if year, _ := strconv.Atoi(token); year >= 1900 && year <= 2099 {
+					matches = append(matches, match{i: i, j: j, pattern: patternDate, token: token, guesses: yearSpace(year)})
+				}
+				continue
+			}
+
+			if year, ok := parseDate(token); ok {
+				matches = append(matches, match{i: i, j: j, pattern: patternDate, token: token, guesses: 365 * yearSpace(year)})
+			}
+		}
+	}
+
+	return matches
+}
+
+// parseDate recognises day, month and year in the orders people usually
+// type them, with or without separators, and returns the year.
+// 识别常见顺序的日、月、年（有无分隔符均可），返回年份
+func parseDate(token string) (int, bool) {
+	var parts []string
+	for _, sep := range []string{"-", "/", ".", " ", "_"} {
+		if strings.Contains(token, sep) {
+			parts = strings.Split(token, sep)
+			break
+		}
+	}
+
+	if parts == nil {
+		if !allDigits(token) {
+			return 0, false
+		}
+		switch len(token) {
+		case 6:
+			parts = []string{token[:2], token[2:4], token[4:]}
+		case 8:
+			// Year first or year last.
+			// 年份在前或者在后
+			if y, ok := validDate(token[:4], token[4:6], token[6:]); ok {
+				return y, true
+			}
+			parts = []string{token[:2], token[2:4], token[4:]}
+		default:
+			return 0, false
+		}
+	}
+
+	if len(parts) != 3 {
+		return 0, false
+	}
+	for _, p := range parts {
+		if p == "" || !allDigits(p) {
+			return 0, false
+		}
+	}
+
+	if y, ok := validDate(parts[2], parts[0], parts[1]); ok {
+		return y, true
+	}
+	if y, ok := validDate(parts[2], parts[1], parts[0]); ok {
+		return y, true
+	}
+	return validDate(parts[0], parts[1], parts[2])
+}
+
+func validDate(y, m, d string) (int, bool) {
+	year, _ := strconv.Atoi(y)
+	month, _ := strconv.Atoi(m)
+	day, _ := strconv.Atoi(d)
+
+	switch len(y) {
+	case 2:
+		if year > 50 {
+			year += 1900
+		} else {
+			year += 2000
+		}
+	case 4:
+	default:
+		return 0, false
+	}
+
+	if year < 1900 || year > 2099 || month < 1 || month > 12 || day < 1 || day > 31 {
+		return 0, false
+	}
+	return year, true
+}
+
+func allDigits(s string) bool {
+	for _, r := range s {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// feedback explains a low score using the longest guessable part of the
+// password, in the same spirit as zxcvbn's warnings.
+// 根据密码中最长的可猜测部分解释为什么得分低，思路和 zxcvbn 的警告一致
+func feedback(score int, sequence []match) (string, []string) {
+	if score > 2 {
+		return "", nil
+	}
+
+	suggestions := []string{"Add another word or two. Uncommon words are better"}
+
+	var longest *match
+	for k := range sequence {
+		m := &sequence[k]
+		if m.pattern == patternBruteforce {
+			continue
+		}
+		if longest == nil || m.j-m.i > longest.j-longest.i {
+			longest = m
+		}
+	}
+
+	if longest == nil {
+		if score <= 1 {
+			return "This password is too short", suggestions
+		}
+		return "", suggestions
+	}
+
+	switch longest.pattern {
+	case patternUserInput:
+		return "Avoid using your name or email address in your password", suggestions
+	case patternDictionary:
+		if longest.common && len(sequence) == 1 {
+			return "This is a very common password", suggestions
+		}
+		if longest.leet {
+			suggestions = append(suggestions, "Predictable substitutions like '@' instead of 'a' don't help very much")
+		}
+		if len(sequence) == 1 {
+			return "A word by itself is easy to guess", suggestions
+		}
+		return "Common words and names are easy to guess", suggestions
+	case patternSequence:
+		return "Sequences like abc or 6543 are easy to guess", append(suggestions, "Avoid sequences")
+	case patternRepeat:
+		return "Repeats like \"aaa\" or \"abcabc\" are easy to guess", append(suggestions, "Avoid repeated words and characters")
+	case patternSpatial:
+		return "Straight rows of keys are easy to guess", append(suggestions, "Use a longer keyboard pattern with more turns")
+	case patternDate:
+		return "Dates and years are often easy to guess", append(suggestions, "Avoid dates and years that are associated with you")
+	}
+
+	return "", suggestions
+}
@@ -0,0 +1,73 @@
+package validator
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPasswordStrength(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		minScore int
+		maxScore int
+		warning  string
+	}{
+		{name: "Common password", password: "password", maxScore: 0, warning: "This is a very common password"},
+		{name: "Leet common password", password: "P@ssw0rd", maxScore: 0, warning: "This is a very common password"},
+		{name: "Reversed common password", password: "drowssap", maxScore: 0, warning: "This is a very common password"},
+		{name: "Sequence", password: "abcdefgh", maxScore: 0, warning: "Sequences like abc or 6543 are easy to guess"},
+		{name: "Repeat", password: "aaaaaaaa", maxScore: 0, warning: `Repeats like "aaa" or "abcabc" are easy to guess`},
+		{name: "Date", password: "19051987", maxScore: 1, warning: "Dates and years are often easy to guess"},
+		{name: "User input", password: "alicesmith", maxScore: 1, warning: "Avoid using your name or email address in your password"},
+		{name: "Passphrase", password: "correct horse battery staple", minScore: 4, maxScore: 4},
+		{name: "Random", password: "x7#kQ9!mZ2@vL5$w", minScore: 4, maxScore: 4},
+		{name: "Very long", password: strings.Repeat("x7#kQ9!mZ2@vL5$w", 10), minScore: 4, maxScore: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := PasswordStrength(tt.password, "Alice Smith", "alice@example.com")
+			if s.Score < tt.minScore || s.Score > tt.maxScore {
+				t.Errorf("got score %d; want %d to %d", s.Score, tt.minScore, tt.maxScore)
+			}
+			if s.Warning != tt.warning {
+				t.Errorf("got warning %q; want %q", s.Warning, tt.warning)
+			}
+		})
+	}
+}
+
+func TestPasswordStrengthUserInputs(t *testing.T) {
+	without := PasswordStrength("alicesmith")
+	with := PasswordStrength("alicesmith", "Alice Smith", "alice@example.com")
+
+	if with.Guesses >= without.Guesses {
+		t.Errorf("got %g guesses with the user's name; want fewer than %g without", with.Guesses, without.Guesses)
+	}
+}
+
+func TestStrengthFeedback(t *testing.T) {
+	tests := []struct {
+		name     string
+		strength Strength
+		want     string
+	}{
+		{"Nothing", Strength{}, ""},
+		{"Warning", Strength{Warning: "This is a very common password"}, "This is a very common password."},
+		{
+			"Warning and suggestions",
+			Strength{Warning: "Sequences like abc or 6543 are easy to guess", Suggestions: []string{"Add another word or two", "Avoid sequences"}},
+			"Sequences like abc or 6543 are easy to guess. Add another word or two. Avoid sequences.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.strength.Feedback()
+			if got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -68,6 +68,17 @@ func PermittedInt(value int, permittedValues ...int) bool {
 	return false
 }
 
+// PermittedString returns true if a value is in a list of permitted strings.
+// 如果某个值位于允许的字符串列表中，返回 true
+func PermittedString(value string, permittedValues ...string) bool {
+	for i := range permittedValues {
+		if value == permittedValues[i] {
+			return true
+		}
+	}
+	return false
+}
+
 // Use the regexp.MustCompile() function to parse a regular expression pattern
 // for sanity checking the format of an email address. This returns a pointer to
 // a 'compiled' regexp.Regexp type, or panics in the event of an error. Parsing